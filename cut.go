@@ -0,0 +1,472 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Cut is the elimination bracket played after the swiss rounds.
+type Cut struct {
+	Tournament *Tournament `json:"-"`
+	Seeds      []PlayerID  // Seeds[0] is the top seed
+	DoubleElim bool
+	Matches    []ElimMatch
+}
+
+// BracketSource says where one of the players in an elimination match comes
+// from.
+type BracketSource struct {
+	Seed  int  // seed number, or 0 if the player comes from another match
+	Match int  // number of the match the player comes from
+	Loser bool // whether it's the loser of that match rather than the winner
+}
+
+// ElimMatch is a match in the cut. The players aren't known until the matches
+// they come from are finished, and the sides aren't known until they've been
+// chosen.
+type ElimMatch struct {
+	Match
+	Round       int
+	Sources     [2]BracketSource
+	Players     [2]PlayerID // higher seed first, or NoPlayer if not known yet
+	SidesChosen bool
+	AutoSides   bool // sides were set by the side selection rules, not chosen
+	Losers      bool // in the losers' bracket of a double elimination cut
+	GrandFinal  bool
+	Reset       bool // grand final rematch, only played if the first is lost by the winners' bracket player
+}
+
+// StartCut finishes the current swiss round and seeds a single or double
+// elimination cut of the given size from the standings. It returns the
+// players dropped for reaching the no-show limit in the round it finished.
+func (t *Tournament) StartCut(size int, doubleElim bool) ([]PlayerID, error) {
+	if t.Cut != nil {
+		return nil, errors.New("The cut has already started")
+	}
+	if t.Draft() != nil {
+		return nil, errors.New("The next swiss round has been paired but not started")
+	}
+	if size != 4 && size != 8 && size != 16 {
+		return nil, errors.New("Cut must be top 4, 8 or 16")
+	}
+	var dropped []PlayerID
+	if len(t.Rounds) != 0 {
+		var e error
+		dropped, e = t.Rounds[len(t.Rounds)-1].Finish()
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	var seeds []PlayerID
+	for _, p := range t.Standings {
+		if !t.Player(p).Dropped && len(seeds) < size {
+			seeds = append(seeds, p)
+		}
+	}
+	if len(seeds) < size {
+		return nil, fmt.Errorf("Not enough players for a top %d cut", size)
+	}
+
+	t.Cut = &Cut{Tournament: t, Seeds: seeds, DoubleElim: doubleElim}
+	if doubleElim {
+		t.Cut.Matches = doubleElimMatches(size)
+	} else {
+		t.Cut.Matches = singleElimMatches(size)
+	}
+	t.Cut.update()
+	return dropped, nil
+}
+
+// bracketOrder returns the seeds in the order they appear down a standard
+// bracket, so that the top seeds can only meet in the latest rounds.
+func bracketOrder(size int) []int {
+	order := []int{1}
+	for n := 2; n <= size; n *= 2 {
+		next := make([]int, 0, n)
+		for _, s := range order {
+			next = append(next, s, n+1-s)
+		}
+		order = next
+	}
+	return order
+}
+
+func singleElimMatches(size int) []ElimMatch {
+	var matches []ElimMatch
+	var round []int
+	order := bracketOrder(size)
+	for i := 0; i < len(order); i += 2 {
+		round = append(round, addElimMatch(&matches, ElimMatch{
+			Sources: [2]BracketSource{{Seed: order[i]}, {Seed: order[i+1]}},
+		}))
+	}
+
+	// each later match is between the winners of a pair of earlier ones
+	for len(round) > 1 {
+		round = winnersRound(&matches, round)
+	}
+
+	return scheduleElimMatches(matches)
+}
+
+// doubleElimMatches builds a double elimination bracket. Players who lose in
+// the winners' bracket drop into the losers' bracket, and the winners of each
+// bracket meet in the grand final.
+func doubleElimMatches(size int) []ElimMatch {
+	var matches []ElimMatch
+	var winners []int
+	order := bracketOrder(size)
+	for i := 0; i < len(order); i += 2 {
+		winners = append(winners, addElimMatch(&matches, ElimMatch{
+			Sources: [2]BracketSource{{Seed: order[i]}, {Seed: order[i+1]}},
+		}))
+	}
+
+	// losers of the first round play each other
+	var losers []int
+	for i := 0; i < len(winners); i += 2 {
+		losers = append(losers, addElimMatch(&matches, ElimMatch{
+			Losers:  true,
+			Sources: [2]BracketSource{{Match: winners[i], Loser: true}, {Match: winners[i+1], Loser: true}},
+		}))
+	}
+
+	for len(winners) > 1 {
+		winners = winnersRound(&matches, winners)
+
+		// losers from the winners' bracket drop in, in reverse order to
+		// put off rematches
+		var dropIn []int
+		for i, l := range losers {
+			dropIn = append(dropIn, addElimMatch(&matches, ElimMatch{
+				Losers:  true,
+				Sources: [2]BracketSource{{Match: l}, {Match: winners[len(winners)-1-i], Loser: true}},
+			}))
+		}
+		losers = dropIn
+
+		if len(losers) > 1 {
+			losers = winnersRound(&matches, losers)
+			for _, l := range losers {
+				matches[l-1].Losers = true
+			}
+		}
+	}
+
+	final := addElimMatch(&matches, ElimMatch{
+		GrandFinal: true,
+		Sources:    [2]BracketSource{{Match: winners[0]}, {Match: losers[0]}},
+	})
+	addElimMatch(&matches, ElimMatch{
+		Reset:   true,
+		Sources: [2]BracketSource{{Match: final}, {Match: final, Loser: true}},
+	})
+
+	return scheduleElimMatches(matches)
+}
+
+// addElimMatch adds a match to the bracket and returns its number.
+func addElimMatch(matches *[]ElimMatch, m ElimMatch) int {
+	m.Number = len(*matches) + 1
+	m.Players = [2]PlayerID{NoPlayer, NoPlayer}
+	m.Corp = NoPlayer
+	m.Runner = NoPlayer
+	*matches = append(*matches, m)
+	return m.Number
+}
+
+// winnersRound adds matches between the winners of each pair of the given
+// matches, and returns the new match numbers.
+func winnersRound(matches *[]ElimMatch, prev []int) []int {
+	var round []int
+	for i := 0; i < len(prev); i += 2 {
+		round = append(round, addElimMatch(matches, ElimMatch{
+			Sources: [2]BracketSource{{Match: prev[i]}, {Match: prev[i+1]}},
+		}))
+	}
+	return round
+}
+
+// elimMatchSorter orders matches by round, keeping the bracket order within
+// each round.
+type elimMatchSorter []ElimMatch
+
+func (s elimMatchSorter) Len() int           { return len(s) }
+func (s elimMatchSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s elimMatchSorter) Less(i, j int) bool { return s[i].Round < s[j].Round }
+
+// scheduleElimMatches puts each match in the earliest round it can be played
+// in, then renumbers the matches in the order they'll be played.
+func scheduleElimMatches(matches []ElimMatch) []ElimMatch {
+	for i := range matches {
+		m := &(matches[i])
+		m.Round = 1
+		for _, s := range m.Sources {
+			if s.Match != 0 && matches[s.Match-1].Round >= m.Round {
+				m.Round = matches[s.Match-1].Round + 1
+			}
+		}
+	}
+
+	sort.Stable(elimMatchSorter(matches))
+	renumber := make(map[int]int)
+	for i := range matches {
+		renumber[matches[i].Number] = i + 1
+	}
+	for i := range matches {
+		matches[i].Number = i + 1
+		for j := range matches[i].Sources {
+			if matches[i].Sources[j].Match != 0 {
+				matches[i].Sources[j].Match = renumber[matches[i].Sources[j].Match]
+			}
+		}
+	}
+	return matches
+}
+
+func (c *Cut) Match(n int) *ElimMatch {
+	if n < 1 || n > len(c.Matches) {
+		return nil
+	}
+	return &(c.Matches[n-1])
+}
+
+// Seed returns the seed number of the given player, or 0 if they're not in
+// the cut.
+func (c *Cut) Seed(p PlayerID) int {
+	for i, s := range c.Seeds {
+		if s == p {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (c *Cut) sourcePlayer(s BracketSource) PlayerID {
+	if s.Seed != 0 {
+		return c.Seeds[s.Seed-1]
+	}
+	m := c.Match(s.Match)
+	if !m.IsDone() {
+		return NoPlayer
+	}
+	if s.Loser {
+		return m.GetOpponent(m.GetWinner())
+	}
+	return m.GetWinner()
+}
+
+// update fills in the players for matches that haven't started yet, and the
+// sides where the side selection rules decide them.
+func (c *Cut) update() {
+	for i := range c.Matches {
+		m := &(c.Matches[i])
+		if m.started() {
+			continue
+		}
+		m.SidesChosen = false
+		m.AutoSides = false
+		m.Corp = NoPlayer
+		m.Runner = NoPlayer
+
+		a := c.sourcePlayer(m.Sources[0])
+		b := c.sourcePlayer(m.Sources[1])
+		if m.Reset && a != c.sourcePlayer(c.Match(m.Sources[0].Match).Sources[1]) {
+			// the winners' bracket player won the grand final, so there's
+			// no rematch
+			a, b = NoPlayer, NoPlayer
+		}
+		if a != NoPlayer && b != NoPlayer && c.Seed(b) < c.Seed(a) {
+			a, b = b, a
+		}
+		m.Players = [2]PlayerID{a, b}
+
+		if c.DoubleElim && m.Ready() {
+			c.assignSides(m)
+		}
+	}
+}
+
+// started returns whether the match has started, meaning the players in it
+// can no longer change.
+func (m ElimMatch) started() bool {
+	return m.Concluded || (m.SidesChosen && !m.AutoSides)
+}
+
+// sideBalance returns how many more times the player has played Corp than
+// Runner in the cut, not counting the given match.
+func (c *Cut) sideBalance(p PlayerID, except int) int {
+	balance := 0
+	for _, m := range c.Matches {
+		if m.Number == except || !m.Concluded {
+			continue
+		}
+		if m.Corp == p {
+			balance += 1
+		} else if m.Runner == p {
+			balance -= 1
+		}
+	}
+	return balance
+}
+
+// assignSides applies the double elimination side selection rules. A grand
+// final rematch is played on the opposite sides to the grand final. Otherwise
+// the player who has played Corp less often in the cut plays Corp, and if
+// they've both played each side equally often, the higher seed chooses.
+func (c *Cut) assignSides(m *ElimMatch) {
+	if m.Reset {
+		final := c.Match(m.Sources[0].Match)
+		m.Corp, m.Runner = final.Runner, final.Corp
+	} else {
+		b0 := c.sideBalance(m.Players[0], m.Number)
+		b1 := c.sideBalance(m.Players[1], m.Number)
+		if b0 < b1 {
+			m.Corp, m.Runner = m.Players[0], m.Players[1]
+		} else if b1 < b0 {
+			m.Corp, m.Runner = m.Players[1], m.Players[0]
+		} else {
+			return
+		}
+	}
+	m.SidesChosen = true
+	m.AutoSides = true
+}
+
+// Ready returns whether both players in the match are known.
+func (m ElimMatch) Ready() bool {
+	return m.Players[0] != NoPlayer && m.Players[1] != NoPlayer
+}
+
+// ChooseSides starts the given match with the given player on Corp.
+func (c *Cut) ChooseSides(n int, corp PlayerID) error {
+	m := c.Match(n)
+	if m == nil {
+		return errors.New("No such match")
+	}
+	if m.SidesChosen {
+		return errors.New("Sides have already been chosen for this match")
+	}
+	if !m.Ready() {
+		return errors.New("Players for this match aren't known yet")
+	}
+	if corp == m.Players[0] {
+		m.Corp, m.Runner = m.Players[0], m.Players[1]
+	} else if corp == m.Players[1] {
+		m.Corp, m.Runner = m.Players[1], m.Players[0]
+	} else {
+		return errors.New("Player is not in this match")
+	}
+	m.SidesChosen = true
+	return nil
+}
+
+// RecordResult records the winner of the given match and moves them on
+// through the bracket. Results can't be changed once a later match that
+// depends on them has started.
+func (c *Cut) RecordResult(n int, winner PlayerID) error {
+	m := c.Match(n)
+	if m == nil {
+		return errors.New("No such match")
+	}
+	if !m.SidesChosen {
+		return errors.New("Sides haven't been chosen for this match")
+	}
+	if winner != m.Corp && winner != m.Runner {
+		return errors.New("Elimination matches must have a winner")
+	}
+	for _, later := range c.Matches {
+		for _, s := range later.Sources {
+			if s.Match == n && later.started() {
+				return fmt.Errorf("Match %d has already started", later.Number)
+			}
+		}
+	}
+	m.Game.RecordResult(winner, false)
+	c.update()
+	return nil
+}
+
+// RecordGameLoss records that a judge gave a player in match n a game loss,
+// so their opponent goes through.
+func (c *Cut) RecordGameLoss(n int, loser PlayerID, ruling string) error {
+	m := c.Match(n)
+	if m == nil {
+		return errors.New("No such match")
+	}
+	e := c.RecordResult(n, m.GetOpponent(loser))
+	if e != nil {
+		return e
+	}
+	c.Match(n).Game.RecordGameLoss(loser, ruling)
+	return nil
+}
+
+// Winner returns the winner of the cut, or NoPlayer if it isn't finished.
+func (c *Cut) Winner() PlayerID {
+	final := c.Matches[len(c.Matches)-1]
+	if c.DoubleElim {
+		reset := final
+		final = c.Matches[len(c.Matches)-2]
+		if final.IsDone() && reset.Ready() {
+			return reset.GetWinner()
+		}
+	}
+	return final.GetWinner()
+}
+
+// Skipped returns whether the match won't be played, which only happens to
+// the grand final rematch.
+func (c *Cut) Skipped(m ElimMatch) bool {
+	return m.Reset && c.Match(m.Sources[0].Match).IsDone() && !m.Ready()
+}
+
+// RoundNumbers lists the rounds of the cut, for the bracket page.
+func (c *Cut) RoundNumbers() []int {
+	var rounds []int
+	for _, m := range c.Matches {
+		if len(rounds) == 0 || rounds[len(rounds)-1] != m.Round {
+			rounds = append(rounds, m.Round)
+		}
+	}
+	return rounds
+}
+
+func (c *Cut) RoundName(round int) string {
+	if c.DoubleElim {
+		for _, m := range c.Matches {
+			if m.Round == round && m.Reset {
+				return "Grand final rematch"
+			} else if m.Round == round && m.GrandFinal {
+				return "Grand final"
+			}
+		}
+		return fmt.Sprintf("Round %d", round)
+	}
+
+	switch c.Matches[len(c.Matches)-1].Round - round {
+	case 0:
+		return "Final"
+	case 1:
+		return "Semifinals"
+	case 2:
+		return "Quarterfinals"
+	default:
+		return fmt.Sprintf("Cut round %d", round)
+	}
+}
+
+// SlotName describes one of the players in a match, whether or not they're
+// known yet.
+func (c *Cut) SlotName(m ElimMatch, i int) string {
+	if m.Players[i] != NoPlayer {
+		return fmt.Sprintf("%s (%d)", c.Tournament.Player(m.Players[i]).Name, c.Seed(m.Players[i]))
+	}
+	s := m.Sources[i]
+	if s.Loser {
+		return fmt.Sprintf("Loser of match %d", s.Match)
+	}
+	return fmt.Sprintf("Winner of match %d", s.Match)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBracketOrder(t *testing.T) {
+	expected := []int{1, 8, 4, 5, 2, 7, 3, 6}
+	order := bracketOrder(8)
+	if len(order) != len(expected) {
+		t.Fatal("Expected", expected, "got", order)
+	}
+	for i := range order {
+		if order[i] != expected[i] {
+			t.Fatal("Expected", expected, "got", order)
+		}
+	}
+}
+
+func TestSingleElimCut(t *testing.T) {
+	tn := playRandomRounds(10, 3)
+	if _, e := tn.StartCut(8, false); e != nil {
+		t.Fatal("Starting cut failed:", e)
+	}
+	c := tn.Cut
+	if len(c.Matches) != 7 {
+		t.Fatal("Expected 7 matches in top 8 cut, got", len(c.Matches))
+	}
+
+	// higher seed always plays corp and wins
+	for round := 1; round <= 3; round++ {
+		for i := range c.Matches {
+			m := &(c.Matches[i])
+			if m.Round != round {
+				continue
+			}
+			if !m.Ready() {
+				t.Fatal("Match", m.Number, "not ready in round", round)
+			}
+			if e := c.ChooseSides(m.Number, m.Players[0]); e != nil {
+				t.Fatal("Choosing sides failed:", e)
+			}
+			if e := c.RecordResult(m.Number, NoPlayer); e == nil {
+				t.Error("Recorded a tie in an elimination match")
+			}
+			if e := c.RecordResult(m.Number, m.Corp); e != nil {
+				t.Fatal("Recording result failed:", e)
+			}
+		}
+	}
+
+	if c.Winner() != c.Seeds[0] {
+		t.Error("Expected top seed to win, got", c.Winner())
+	}
+	if e := c.RecordResult(1, c.Match(1).Runner); e == nil {
+		t.Error("Changed result of match 1 after later matches started")
+	}
+	if e := tn.NextRound(true); e == nil {
+		t.Error("Paired a swiss round after the cut started")
+	}
+}
+
+// playCut plays out the cut, with winners chosen by pick.
+func playCut(t *testing.T, c *Cut, pick func(m *ElimMatch) PlayerID) {
+	for _, round := range c.RoundNumbers() {
+		for i := range c.Matches {
+			m := &(c.Matches[i])
+			if m.Round != round || c.Skipped(*m) {
+				continue
+			}
+			if !m.Ready() {
+				t.Fatal("Match", m.Number, "not ready in round", round)
+			}
+			if !m.SidesChosen {
+				if e := c.ChooseSides(m.Number, m.Players[0]); e != nil {
+					t.Fatal("Choosing sides failed:", e)
+				}
+			}
+			if e := c.RecordResult(m.Number, pick(m)); e != nil {
+				t.Fatal("Recording result failed:", e)
+			}
+		}
+	}
+}
+
+func TestDoubleElimCut(t *testing.T) {
+	for _, size := range []int{4, 8, 16} {
+		tn := playRandomRounds(size, 3)
+		if _, e := tn.StartCut(size, true); e != nil {
+			t.Fatal("Starting cut failed:", e)
+		}
+		c := tn.Cut
+		playCut(t, c, func(m *ElimMatch) PlayerID {
+			if rand.Intn(2) == 0 {
+				return m.Corp
+			}
+			return m.Runner
+		})
+
+		winner := c.Winner()
+		if winner == NoPlayer {
+			t.Fatal("No winner for top", size, "cut")
+		}
+		losses := make(map[PlayerID]int)
+		for _, m := range c.Matches {
+			if m.IsDone() {
+				losses[m.GetOpponent(m.GetWinner())] += 1
+			}
+		}
+		for _, p := range c.Seeds {
+			if p != winner && losses[p] != 2 {
+				t.Error("In top", size, "cut, expected 2 losses for eliminated player, got", losses[p])
+			}
+		}
+		if losses[winner] > 1 {
+			t.Error("In top", size, "cut, winner lost", losses[winner], "times")
+		}
+	}
+}
+
+func TestGrandFinalRematch(t *testing.T) {
+	tn := playRandomRounds(4, 3)
+	tn.StartCut(4, true)
+	c := tn.Cut
+
+	// top seed wins everything until the grand final, then loses it
+	playCut(t, c, func(m *ElimMatch) PlayerID {
+		if m.GrandFinal || m.Reset || m.GetOpponent(c.Seeds[0]) == NoPlayer {
+			return m.Players[1]
+		}
+		return c.Seeds[0]
+	})
+
+	final := c.Matches[len(c.Matches)-2]
+	reset := c.Matches[len(c.Matches)-1]
+	if !reset.IsDone() {
+		t.Fatal("Expected grand final rematch to be played")
+	}
+	if reset.Corp != final.Runner || reset.Runner != final.Corp {
+		t.Error("Expected grand final rematch on opposite sides to grand final")
+	}
+	if c.Winner() != reset.GetWinner() {
+		t.Error("Expected winner of rematch to win the cut")
+	}
+}
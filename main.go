@@ -1,22 +1,48 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"html/template"
-	"math/rand"
+	"net"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 )
 
 var tournament Tournament
 var filename string
 
+// tournamentLock is held by every handler while it runs, and by pairing in
+// the background while it changes the tournament.
+var tournamentLock sync.Mutex
+
+var templateFuncs = template.FuncMap{
+	"roundStatus":           func() string { return tournament.RoundStatus() },
+	"registrationOpen":      registrationOpen,
+	"pairingProfiles":       func() []PairingProfile { return pairingProfiles },
+	"scoringSchemes":        func() []Scoring { return scoringSchemes },
+	"tiebreakers":           func() []Tiebreaker { return tiebreakers },
+	"missedRoundTreatments": func() []MissedRoundTreatment { return missedRoundTreatments },
+	"tiebreakPresets":       func() []TiebreakPreset { return tiebreakPresets },
+	// the current tiebreaker keys, with a blank for each unused tiebreaker
+	"tiebreakerSlots": func() []string {
+		var slots []string
+		for _, tb := range tournament.TiebreakerChain() {
+			slots = append(slots, tb.Key)
+		}
+		for len(slots) < len(tiebreakers) {
+			slots = append(slots, "")
+		}
+		return slots
+	},
+}
+
 func applyTemplate(w http.ResponseWriter, src string, data interface{}) error {
-	t, e := template.New("base").Parse(frameTemplate)
+	t, e := template.New("base").Funcs(templateFuncs).Parse(frameTemplate)
 	if e != nil {
 		fmt.Println(e.Error())
 		return e
@@ -35,11 +61,17 @@ func applyTemplate(w http.ResponseWriter, src string, data interface{}) error {
 }
 
 func playerList(w http.ResponseWriter, r *http.Request) {
-	applyTemplate(w, playerListTemplate, tournament)
+	applyTemplate(w, playerListTemplate, &tournament)
 }
 
+// standings handles /standings, optionally with the standings as they were
+// after an earlier round
 func standings(w http.ResponseWriter, r *http.Request) {
-	applyTemplate(w, standingsTemplate, &tournament)
+	round, e := strconv.Atoi(r.FormValue("round"))
+	if e != nil || round < 0 || round > len(tournament.Rounds) {
+		round = len(tournament.Rounds)
+	}
+	applyTemplate(w, standingsTemplate, tournament.StandingsAfter(round))
 }
 
 func playerForm(w http.ResponseWriter, r *http.Request) {
@@ -54,6 +86,9 @@ func playerForm(w http.ResponseWriter, r *http.Request) {
 	name := r.FormValue("name")
 	corp := r.FormValue("corp")
 	runner := r.FormValue("runner")
+	team := r.FormValue("team")
+	earnedByes := r.FormValue("earned-byes")
+	missedAs := r.FormValue("missed-as")
 	idString := r.FormValue("player-id")
 	if idString != "" {
 		idTemp, err := strconv.Atoi(idString)
@@ -62,7 +97,17 @@ func playerForm(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if r.Method == "POST" {
+	byes, e := strconv.Atoi(earnedByes)
+	if earnedByes == "" {
+		byes, e = 0, nil
+	}
+	if e != nil || byes < 0 {
+		e = errors.New("Earned byes must be a whole number")
+	} else if !knownMissedRoundTreatment(missedAs) {
+		e = errors.New("Unknown treatment for missed rounds")
+	}
+
+	if r.Method == "POST" && e == nil {
 		if edit {
 			player := tournament.Player(id)
 			if player != nil {
@@ -70,6 +115,9 @@ func playerForm(w http.ResponseWriter, r *http.Request) {
 				player.Name = name
 				player.Corp = corp
 				player.Runner = runner
+				player.Team = team
+				player.EarnedByes = byes
+				tournament.SetMissedRounds(id, missedAs)
 				if name == oldName {
 					saveWrapper(fmt.Sprintf("Edited player %s", name))
 				} else {
@@ -83,6 +131,9 @@ func playerForm(w http.ResponseWriter, r *http.Request) {
 			if e != nil {
 				fmt.Println("Error adding player:", e)
 			} else {
+				tournament.Players[len(tournament.Players)-1].Team = team
+				tournament.Players[len(tournament.Players)-1].EarnedByes = byes
+				tournament.SetMissedRounds(PlayerID(len(tournament.Players)), missedAs)
 				saveWrapper(fmt.Sprintf("Added player %s", name))
 			}
 		}
@@ -99,14 +150,32 @@ func playerForm(w http.ResponseWriter, r *http.Request) {
 		name = player.Name
 		corp = player.Corp
 		runner = player.Runner
+		team = player.Team
+		if player.EarnedByes != 0 {
+			earnedByes = strconv.Itoa(player.EarnedByes)
+		}
+		missedAs = player.MissedAs
 	}
 
+	missedRounds := tournament.StartedRounds()
+	if edit {
+		missedRounds = tournament.Player(id).MissedRounds
+	} else if tournament.Draft() != nil {
+		data["draft"] = "draft"
+	}
+	if missedRounds != 0 {
+		data["missedRounds"] = strconv.Itoa(missedRounds)
+	}
+	data["missedAs"] = missedAs
+
 	if e != nil {
 		data["error"] = e.Error()
 	}
 	data["name"] = name
 	data["corp"] = corp
 	data["runner"] = runner
+	data["team"] = team
+	data["earnedByes"] = earnedByes
 	data["id"] = idString
 	if !edit {
 		data["add"] = "add"
@@ -135,6 +204,10 @@ func changePlayer(w http.ResponseWriter, r *http.Request) {
 			id = PlayerID(idTemp)
 		}
 	}
+	if tournament.Player(id) == nil {
+		applyTemplate(w, errorTemplate, errors.New("No such player"))
+		return
+	}
 
 	if r.FormValue("drop") != "" {
 		tournament.DropPlayer(id)
@@ -142,51 +215,548 @@ func changePlayer(w http.ResponseWriter, r *http.Request) {
 	} else if r.FormValue("re-add") != "" {
 		tournament.ReAddPlayer(id)
 		saveWrapper(fmt.Sprintf("Re-added player %s", tournament.Player(id).Name))
+	} else if checkIn := r.FormValue("check-in") != ""; checkIn || r.FormValue("check-out") != "" {
+		e := tournament.CheckIn(id, checkIn)
+		if e != nil {
+			applyTemplate(w, errorTemplate, e)
+			return
+		}
+		if checkIn {
+			saveWrapper(fmt.Sprintf("Checked in player %s", tournament.Player(id).Name))
+		} else {
+			saveWrapper(fmt.Sprintf("Undid check-in for player %s", tournament.Player(id).Name))
+		}
 	}
 
 	seeOther(w, "/players")
 }
 
-func menu(w http.ResponseWriter, r *http.Request) {
-	applyTemplate(w, menuTemplate, nil)
+// formPlayer returns the player whose id was sent in the given form field.
+func formPlayer(r *http.Request, field string) (*Player, error) {
+	id, e := strconv.Atoi(r.FormValue(field))
+	if e != nil || tournament.Player(PlayerID(id)) == nil {
+		return nil, errors.New("No such player")
+	}
+	return tournament.Player(PlayerID(id)), nil
 }
 
-func startRound(w http.ResponseWriter, r *http.Request) {
+func restrictions(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		pa, ea := formPlayer(r, "a")
+		pb, eb := formPlayer(r, "b")
+		if ea != nil || eb != nil {
+			applyTemplate(w, errorTemplate, errors.New("No such player"))
+			return
+		}
+		if r.FormValue("remove") != "" {
+			tournament.AllowPairing(pa.PlayerID, pb.PlayerID)
+			saveWrapper(fmt.Sprintf("Allowed %s and %s to play each other", pa.Name, pb.Name))
+		} else {
+			e := tournament.AvoidPairing(pa.PlayerID, pb.PlayerID)
+			if e != nil {
+				applyTemplate(w, errorTemplate, e)
+				return
+			}
+			saveWrapper(fmt.Sprintf("Kept %s and %s apart", pa.Name, pb.Name))
+		}
+		seeOther(w, "/restrictions")
+		return
+	}
+	applyTemplate(w, restrictionsTemplate, &tournament)
+}
+
+func adjustments(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		if r.FormValue("remove") != "" {
+			i, e := strconv.Atoi(r.FormValue("adjustment"))
+			if e != nil || i < 0 || i >= len(tournament.Adjustments) {
+				applyTemplate(w, errorTemplate, errors.New("No such adjustment"))
+				return
+			}
+			name := tournament.Player(tournament.Adjustments[i].Player).Name
+			tournament.RemoveAdjustment(i)
+			saveWrapper(fmt.Sprintf("Removed a prestige adjustment for %s", name))
+			seeOther(w, "/adjustments")
+			return
+		}
+
+		player, playerErr := formPlayer(r, "player")
+		round, roundErr := strconv.Atoi(r.FormValue("round"))
+		prestige, prestigeErr := strconv.Atoi(r.FormValue("prestige"))
+		var e error
+		if playerErr != nil {
+			e = playerErr
+		} else if roundErr != nil {
+			e = errors.New("Round must be a whole number")
+		} else if prestigeErr != nil {
+			e = errors.New("Prestige must be a whole number")
+		} else {
+			e = tournament.AddAdjustment(Adjustment{
+				Player:   player.PlayerID,
+				Round:    round,
+				Prestige: prestige,
+				Reason:   r.FormValue("reason"),
+			})
+		}
+		if e != nil {
+			applyTemplate(w, errorTemplate, e)
+			return
+		}
+		saveWrapper(fmt.Sprintf("Adjusted %s's prestige by %+d", player.Name, prestige))
+		seeOther(w, "/adjustments")
+		return
+	}
+	applyTemplate(w, adjustmentsTemplate, &tournament)
+}
+
+// registrations handles the TO's side of self registration
+func registrations(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		i, e := strconv.Atoi(r.FormValue("registration"))
+		if e != nil {
+			i = -1
+		}
+		reg, e := tournament.registration(i)
+		if e != nil {
+			applyTemplate(w, errorTemplate, e)
+			return
+		}
+		var reason string
+		if r.FormValue("approve") != "" {
+			e = tournament.ApproveRegistration(i)
+			reason = fmt.Sprintf("Added player %s from registration", reg.Name)
+		} else if r.FormValue("merge") != "" {
+			var p *Player
+			p, e = formPlayer(r, "player")
+			if e == nil {
+				e = tournament.MergeRegistration(i, p.PlayerID)
+				reason = fmt.Sprintf("Merged registration %s into player %s", reg.Name, p.Name)
+			}
+		} else if r.FormValue("reject") != "" {
+			e = tournament.RejectRegistration(i)
+			reason = fmt.Sprintf("Rejected registration %s", reg.Name)
+		} else {
+			e = errors.New("Unknown action")
+		}
+		if e != nil {
+			applyTemplate(w, errorTemplate, e)
+			return
+		}
+		saveWrapper(reason)
+		seeOther(w, "/registrations")
+		return
+	}
+	applyTemplate(w, registrationsTemplate, &tournament)
+}
+
+// registrationOpen returns whether players can register and check in, which
+// they can't once the first round has started pairing.
+func registrationOpen() bool {
+	return tournament.RegistrationOpen() && !pairing.isRunning()
+}
+
+// register is the public page where players enter themselves
+func register(w http.ResponseWriter, r *http.Request) {
+	data := map[string]string{}
+	if !registrationOpen() {
+		data["closed"] = "closed"
+	} else if r.Method == "POST" {
+		data["name"] = r.FormValue("name")
+		data["corp"] = r.FormValue("corp")
+		data["runner"] = r.FormValue("runner")
+		e := tournament.Register(data["name"], data["corp"], data["runner"])
+		if e != nil {
+			data["error"] = e.Error()
+		} else {
+			data["done"] = "done"
+			if tournament.RequireCheckIn {
+				data["checkIn"] = "checkIn"
+			}
+			saveWrapper(fmt.Sprintf("%s registered", data["name"]))
+		}
+	}
+	applyTemplate(w, registerTemplate, data)
+}
+
+// checkIn is the public page where players check in before round 1
+func checkIn(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
-		e := tournament.NextRound()
-		saveWrapper(fmt.Sprintf("Paired round %d", len(tournament.Rounds)))
+		p, e := formPlayer(r, "player")
+		if e == nil {
+			e = tournament.CheckIn(p.PlayerID, true)
+		}
 		if e != nil {
 			applyTemplate(w, errorTemplate, e)
+			return
+		}
+		saveWrapper(fmt.Sprintf("%s checked in", p.Name))
+		applyTemplate(w, checkedInTemplate, map[string]string{"name": p.Name})
+		return
+	}
+	applyTemplate(w, checkInTemplate, &tournament)
+}
+
+func settings(w http.ResponseWriter, r *http.Request) {
+	data := map[string]string{}
+	swissRounds := r.FormValue("swiss-rounds")
+	restrictionRounds := r.FormValue("restriction-rounds")
+	pairingTime := r.FormValue("pairing-time")
+	profile := r.FormValue("profile")
+	scoring := r.FormValue("scoring")
+	if scoring == "" {
+		scoring = defaultScoring.Key
+	}
+	idPrestige := r.FormValue("id-prestige")
+	noShowLimit := r.FormValue("no-show-limit")
+	twoGames := r.FormValue("two-game-rounds") != ""
+	requireCheckIn := r.FormValue("require-check-in") != ""
+	preset := r.FormValue("tiebreak-preset")
+
+	if r.Method == "POST" {
+		n, e := strconv.Atoi(swissRounds)
+		if swissRounds == "" {
+			n, e = 0, nil
+		}
+		rr, rErr := strconv.Atoi(restrictionRounds)
+		if restrictionRounds == "" {
+			rr, rErr = 0, nil
+		}
+		pt, pErr := strconv.Atoi(pairingTime)
+		if pairingTime == "" {
+			pt, pErr = 0, nil
+		}
+		idp, idErr := strconv.Atoi(idPrestige)
+		if idPrestige == "" {
+			idErr = nil
+		}
+		ns, nsErr := strconv.Atoi(noShowLimit)
+		if noShowLimit == "" {
+			ns, nsErr = 0, nil
+		}
+		var chain []string
+		if p, ok := findTiebreakPreset(preset); ok {
+			chain = p.Tiebreakers
+		} else {
+			seen := make(map[string]bool)
+			for _, key := range r.Form["tiebreaker"] {
+				if key != "" && !seen[key] {
+					chain = append(chain, key)
+					seen[key] = true
+				}
+			}
+		}
+		var tbErr error
+		for _, key := range chain {
+			if _, ok := findTiebreaker(key); !ok {
+				tbErr = errors.New("Unknown tiebreaker")
+			}
+		}
+		if e != nil || n < 0 {
+			e = errors.New("Number of swiss rounds must be a whole number")
+		} else if rErr != nil || rr < 0 {
+			e = errors.New("Number of rounds with pairing restrictions must be a whole number")
+		} else if pErr != nil || pt < 0 {
+			e = errors.New("Pairing time limit must be a whole number of seconds")
+		} else if profile != "" && !knownProfile(profile) {
+			e = errors.New("Unknown pairing profile")
+		} else if !knownScoring(scoring) {
+			e = errors.New("Unknown scoring scheme")
+		} else if scoring != tournament.Scoring().Key && len(tournament.Rounds) != 0 && tournament.Rounds[0].Started {
+			e = errors.New("The scoring scheme can't be changed once the first round has started")
+		} else if idErr != nil || idp < 0 {
+			e = errors.New("Prestige for an intentional draw must be a whole number")
+		} else if nsErr != nil || ns < 0 {
+			e = errors.New("Number of no-shows before dropping must be a whole number")
+		} else if preset != "" && chain == nil {
+			e = errors.New("Unknown tiebreaker preset")
+		} else if tbErr != nil {
+			e = tbErr
+		} else if twoGames != tournament.TwoGameRounds && len(tournament.Rounds) != 0 {
+			e = errors.New("The round format can't be changed after the first round")
+		}
+
+		if e != nil {
+			data["error"] = e.Error()
 		} else {
-			seeOther(w, "/matches")
+			tournament.SwissRounds = n
+			tournament.TwoGameRounds = twoGames
+			tournament.RestrictionRounds = rr
+			tournament.PairingTimeLimit = pt
+			tournament.PairingProfile = profile
+			tournament.ScoringScheme = scoring
+			if idPrestige == "" {
+				tournament.IDPrestige = nil
+			} else {
+				tournament.IDPrestige = &idp
+			}
+			tournament.NoShowDropLimit = ns
+			tournament.Tiebreakers = chain
+			tournament.RequireCheckIn = requireCheckIn
+			tournament.RecomputeStandings()
+			saveWrapper("Changed tournament settings")
+			seeOther(w, "/")
+			return
 		}
 	} else {
+		if tournament.SwissRounds != 0 {
+			swissRounds = strconv.Itoa(tournament.SwissRounds)
+		}
+		twoGames = tournament.TwoGameRounds
+		requireCheckIn = tournament.RequireCheckIn
+		if tournament.RestrictionRounds != 0 {
+			restrictionRounds = strconv.Itoa(tournament.RestrictionRounds)
+		}
+		if tournament.PairingTimeLimit != 0 {
+			pairingTime = strconv.Itoa(tournament.PairingTimeLimit)
+		}
+		profile = tournament.Profile().Key
+		scoring = tournament.Scoring().Key
+		if tournament.IDPrestige != nil {
+			idPrestige = strconv.Itoa(*tournament.IDPrestige)
+		}
+		if tournament.NoShowDropLimit != 0 {
+			noShowLimit = strconv.Itoa(tournament.NoShowDropLimit)
+		}
+	}
+
+	data["swissRounds"] = swissRounds
+	data["restrictionRounds"] = restrictionRounds
+	data["pairingTime"] = pairingTime
+	data["profile"] = profile
+	data["scoring"] = scoring
+	data["idPrestige"] = idPrestige
+	data["noShowLimit"] = noShowLimit
+	data["defaultPairingTime"] = strconv.Itoa(defaultPairingTimeLimit)
+	if twoGames {
+		data["twoGames"] = "twoGames"
+	}
+	if requireCheckIn {
+		data["requireCheckIn"] = "requireCheckIn"
+	}
+	players := len(tournament.activePlayers())
+	data["players"] = strconv.Itoa(players)
+	data["suggested"] = strconv.Itoa(suggestedSwissRounds(players))
+	applyTemplate(w, settingsTemplate, data)
+}
+
+func menu(w http.ResponseWriter, r *http.Request) {
+	applyTemplate(w, menuTemplate, nil)
+}
+
+// pairingJob keeps track of pairing that's running in the background, so the
+// browser can show how it's going instead of waiting on one long request.
+type pairingJob struct {
+	sync.Mutex
+	running bool
+	done    int
+	total   int
+	noShows map[string]string // players dropped before pairing, for noShowsTemplate
+}
+
+var pairing pairingJob
+
+func (j *pairingJob) isRunning() bool {
+	j.Lock()
+	defer j.Unlock()
+	return j.running
+}
+
+// startPairing pairs r in the background with the given seed, giving it the
+// tournament's pairing time limit. Once it's paired, it's added to the
+// tournament, or replaces the draft it's a copy of, and saved with the given
+// reason. The players dropped for no-shows in the round before are shown
+// once it's done. The caller must hold the tournament lock.
+func startPairing(r Round, seed int64, reason string, dropped []PlayerID) error {
+	pairing.Lock()
+	defer pairing.Unlock()
+	if pairing.running {
+		return errors.New("Pairing is already in progress")
+	}
+	pairing.running = true
+	pairing.done, pairing.total = 0, 0
+	pairing.noShows = nil
+	if len(dropped) != 0 {
+		pairing.noShows = noShowData(fmt.Sprintf("Paired round %d", r.Number), "/matches", dropped, nil)
+		reason += ". " + noShowNote(dropped, nil)
+	}
+
+	// pairing doesn't hold the lock, so it mustn't change the tournament;
+	// working out the standings now means it only has to read them
+	tournament.currentStandings()
+	limit := tournament.PairingTime()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), limit)
+		defer cancel()
+		r.pair(ctx, seed, func(done, total int) {
+			pairing.Lock()
+			pairing.done, pairing.total = done, total
+			pairing.Unlock()
+		})
+
+		tournamentLock.Lock()
+		defer tournamentLock.Unlock()
+		if d := tournament.Draft(); d != nil && d.Number == r.Number {
+			*d = r
+		} else {
+			tournament.Rounds = append(tournament.Rounds, r)
+		}
+		saveWrapper(reason)
+		pairing.Lock()
+		pairing.running = false
+		pairing.Unlock()
+	}()
+	return nil
+}
+
+func startRound(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
 		seeOther(w, "/")
+		return
+	}
+	next, dropped, e := tournament.PrepareNextRound(r.FormValue("extra") != "")
+	if e == errSwissFinished {
+		applyTemplate(w, extraRoundTemplate, e)
+		return
+	}
+	if e == nil {
+		e = startPairing(next, tournament.random().Int63(), fmt.Sprintf("Paired round %d", next.Number), dropped)
+	}
+	if e != nil {
+		applyTemplate(w, errorTemplate, e)
+		return
+	}
+	seeOther(w, "/pairing")
+}
+
+// pairingStatus shows how pairing is going, then the new pairings once it's
+// done.
+func pairingStatus(w http.ResponseWriter, r *http.Request) {
+	pairing.Lock()
+	running, done, total, noShows := pairing.running, pairing.done, pairing.total, pairing.noShows
+	pairing.Unlock()
+
+	if running {
+		data := map[string]string{
+			"done":  strconv.Itoa(done),
+			"total": strconv.Itoa(total),
+			"limit": strconv.Itoa(int(tournament.PairingTime().Seconds())),
+		}
+		applyTemplate(w, pairingTemplate, data)
+	} else if noShows != nil {
+		applyTemplate(w, noShowsTemplate, noShows)
+	} else {
+		seeOther(w, "/matches")
 	}
 }
 
 func finishRound(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" && len(tournament.Rounds) > 0 {
-		e := tournament.Rounds[len(tournament.Rounds)-1].Finish()
+		dropped, e := tournament.Rounds[len(tournament.Rounds)-1].Finish()
 		if e != nil {
 			applyTemplate(w, errorTemplate, e)
 			return
 		}
+		if len(dropped) != 0 {
+			saveWrapper("Finished round & updated standings. " + noShowNote(dropped, nil))
+			applyTemplate(w, noShowsTemplate, noShowData("Finished round", "/", dropped, nil))
+			return
+		}
 		saveWrapper("Finished round & updated standings")
 	}
 	seeOther(w, "/")
 }
 
+// noShowNote describes the players dropped or re-added because of the
+// no-show limit, for a save reason.
+func noShowNote(dropped, reAdded []PlayerID) string {
+	var notes []string
+	if len(dropped) != 0 {
+		notes = append(notes, fmt.Sprintf("Dropped after %d no-shows: %s", tournament.NoShowDropLimit, playerNames(dropped)))
+	}
+	if len(reAdded) != 0 {
+		notes = append(notes, fmt.Sprintf("Re-added with fewer than %d no-shows: %s", tournament.NoShowDropLimit, playerNames(reAdded)))
+	}
+	return strings.Join(notes, ". ")
+}
+
+// noShowData is the data for noShowsTemplate.
+func noShowData(title, next string, dropped, reAdded []PlayerID) map[string]string {
+	data := map[string]string{"title": title, "next": next, "limit": strconv.Itoa(tournament.NoShowDropLimit)}
+	if len(dropped) != 0 {
+		data["dropped"] = playerNames(dropped)
+	}
+	if len(reAdded) != 0 {
+		data["reAdded"] = playerNames(reAdded)
+	}
+	return data
+}
+
+func playerNames(players []PlayerID) string {
+	var names []string
+	for _, p := range players {
+		names = append(names, tournament.Player(p).Name)
+	}
+	return strings.Join(names, ", ")
+}
+
 func matches(w http.ResponseWriter, r *http.Request) {
 	if len(tournament.Rounds) == 0 {
 		applyTemplate(w, noMatchesTemplate, Round{})
+	} else if d := tournament.Draft(); d != nil {
+		applyTemplate(w, draftTemplate, d)
 	} else {
 		applyTemplate(w, matchesTemplate, tournament.Rounds[len(tournament.Rounds)-1])
 	}
 }
 
+// draft handles changes to the pairings of a round that hasn't started
+func draft(w http.ResponseWriter, r *http.Request) {
+	d := tournament.Draft()
+	if r.Method != "POST" || d == nil {
+		seeOther(w, "/matches")
+		return
+	}
+	var e error
+	var reason string
+	switch r.FormValue("action") {
+	case "flip":
+		matchNum, _ := strconv.Atoi(r.FormValue("match"))
+		e = d.FlipSides(matchNum)
+		reason = fmt.Sprintf("Swapped sides in round %d match %d", d.Number, matchNum)
+	case "swap":
+		a, _ := strconv.Atoi(r.FormValue("a"))
+		b, _ := strconv.Atoi(r.FormValue("b"))
+		e = d.SwapPlayers(PlayerID(a), PlayerID(b))
+		reason = fmt.Sprintf("Swapped players in round %d pairings", d.Number)
+	case "reroll":
+		// the draft is paired again as a copy, which replaces it once it's
+		// done, so that it can still be shown in the meantime
+		e = startPairing(*d, tournament.random().Int63(), fmt.Sprintf("Paired round %d again", d.Number), nil)
+		if e == nil {
+			seeOther(w, "/pairing")
+			return
+		}
+	case "discard":
+		reason = fmt.Sprintf("Discarded pairings for round %d", d.Number)
+		e = tournament.DiscardDraft()
+	case "start":
+		d.Start()
+		reason = fmt.Sprintf("Started round %d", d.Number)
+	default:
+		e = errors.New("Unknown action")
+	}
+
+	if e != nil {
+		applyTemplate(w, errorTemplate, e)
+		return
+	}
+	saveWrapper(reason)
+	seeOther(w, "/matches")
+}
+
 func rounds(w http.ResponseWriter, r *http.Request) {
-	t, e := template.New("base").Parse(frameTemplate)
+	t, e := template.New("base").Funcs(templateFuncs).Parse(frameTemplate)
 	if e != nil {
 		fmt.Println(e.Error())
 	}
@@ -204,23 +774,55 @@ func rounds(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// explainRound handles /rounds/{n}/explain
+func explainRound(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[2] != "explain" {
+		http.NotFound(w, r)
+		return
+	}
+	n, e := strconv.Atoi(parts[1])
+	if e != nil || n < 1 || n > len(tournament.Rounds) {
+		http.NotFound(w, r)
+		return
+	}
+	applyTemplate(w, explainTemplate, &(tournament.Rounds[n-1]))
+}
+
 func recordResult(w http.ResponseWriter, r *http.Request) {
-	roundNum, rErr := strconv.ParseInt(r.FormValue("round"), 10, 0)
-	matchNum, mErr := strconv.ParseInt(r.FormValue("match"), 10, 0)
+	cut := r.FormValue("cut") != ""
+	roundNum, rErr := strconv.Atoi(r.FormValue("round"))
+	matchNum, mErr := strconv.Atoi(r.FormValue("match"))
 
-	if mErr != nil || rErr != nil {
-		seeOther(w, "/matches")
+	var match *Match
+	backTo := "/matches"
+	if cut {
+		backTo = "/cut"
+		if mErr == nil && tournament.Cut != nil {
+			m := tournament.Cut.Match(matchNum)
+			if m != nil && m.SidesChosen {
+				match = &(m.Match)
+			}
+		}
+	} else if mErr == nil && rErr == nil {
+		match = tournament.Match(MatchID{roundNum, matchNum})
+		if match != nil && !tournament.Rounds[roundNum-1].Started {
+			match = nil
+		}
 	}
 
-	match := &(tournament.Rounds[roundNum-1].Matches[matchNum-1])
+	if match == nil || match.IsBye() {
+		seeOther(w, backTo)
+		return
+	}
 
 	if r.Method == "POST" {
 
 		result := r.FormValue("winner")
 		var winner PlayerID
-		if result == "corp" {
+		if result == "corp" || result == "runner-game-loss" {
 			winner = match.Game.Corp
-		} else if result == "runner" {
+		} else if result == "runner" || result == "corp-game-loss" {
 			winner = match.Game.Runner
 		}
 
@@ -229,35 +831,213 @@ func recordResult(w http.ResponseWriter, r *http.Request) {
 			timed = true
 		}
 
-		match.Game.RecordResult(winner, timed)
-		saveWrapper(fmt.Sprintf("Recorded result for %s vs %s. Winner: %s, Went to time: %t", tournament.Player(match.Game.Corp).Name, tournament.Player(match.Game.Runner).Name, result, timed))
+		ruling := strings.TrimSpace(r.FormValue("ruling"))
+		if ruling == "" && (isPenaltyResult(result) || (match.SecondGame != nil && isPenaltyResult(r.FormValue("winner2")))) {
+			applyTemplate(w, errorTemplate, errors.New("Double losses and game losses need the judge's ruling"))
+			return
+		}
+		var rulingNote string
+		if ruling != "" {
+			rulingNote = fmt.Sprintf(". Judge ruling: %s", ruling)
+		}
 
-		seeOther(w, "/matches")
+		var reason string
+		if (result == "corp-no-show" || result == "runner-no-show") && !cut {
+			// a no-show forfeits the whole match
+			absent := match.Game.Corp
+			if result == "runner-no-show" {
+				absent = match.Game.Runner
+			}
+			match.Game.RecordForfeit(absent)
+			if match.SecondGame != nil {
+				match.SecondGame.RecordForfeit(absent)
+			}
+			reason = fmt.Sprintf("Recorded forfeit for %s vs %s. No-show: %s", tournament.Player(match.Game.Corp).Name, tournament.Player(match.Game.Runner).Name, tournament.Player(absent).Name)
+		} else if result == "id" && !cut {
+			// an intentional draw covers the whole match
+			prestige := tournament.IntentionalDrawPrestige()
+			match.Game.RecordIntentionalDraw(prestige)
+			if match.SecondGame != nil {
+				match.SecondGame.RecordIntentionalDraw(prestige)
+			}
+			reason = fmt.Sprintf("Recorded intentional draw for %s vs %s", tournament.Player(match.Game.Corp).Name, tournament.Player(match.Game.Runner).Name)
+		} else if match.SecondGame != nil {
+			result2 := r.FormValue("winner2")
+			timed2 := r.FormValue("timed2") != ""
+
+			recordGame(&match.Game, result, timed, ruling)
+			recordGame(match.SecondGame, result2, timed2, ruling)
+			reason = fmt.Sprintf("Recorded result for %s vs %s. Game 1 winner: %s, Went to time: %t. Game 2 winner: %s, Went to time: %t%s", tournament.Player(match.Game.Corp).Name, tournament.Player(match.Game.Runner).Name, result, timed, result2, timed2, rulingNote)
+		} else if cut {
+			var e error
+			if result == "corp-game-loss" || result == "runner-game-loss" {
+				e = tournament.Cut.RecordGameLoss(matchNum, match.GetOpponent(winner), ruling)
+			} else {
+				e = tournament.Cut.RecordResult(matchNum, winner)
+			}
+			if e != nil {
+				applyTemplate(w, errorTemplate, e)
+				return
+			}
+			reason = fmt.Sprintf("Recorded cut result for %s vs %s. Winner: %s%s", tournament.Player(match.Game.Corp).Name, tournament.Player(match.Game.Runner).Name, result, rulingNote)
+		} else {
+			recordGame(&match.Game, result, timed, ruling)
+			reason = fmt.Sprintf("Recorded result for %s vs %s. Winner: %s, Went to time: %t%s", tournament.Player(match.Game.Corp).Name, tournament.Player(match.Game.Runner).Name, result, timed, rulingNote)
+		}
+
+		if !cut && tournament.Rounds[roundNum-1].Finished {
+			dropped, reAdded := tournament.CorrectResults(roundNum)
+			backTo = "/rounds"
+			reason = fmt.Sprintf("Corrected round %d: %s", roundNum, reason)
+			if len(dropped) != 0 || len(reAdded) != 0 {
+				saveWrapper(reason + ". " + noShowNote(dropped, reAdded))
+				applyTemplate(w, noShowsTemplate, noShowData(fmt.Sprintf("Corrected round %d", roundNum), backTo, dropped, reAdded))
+				return
+			}
+		}
+		saveWrapper(reason)
+		seeOther(w, backTo)
 	} else {
 		data := map[string]string{"recordurl": r.URL.Path}
 		data["roundNum"] = r.FormValue("round")
 		data["matchNum"] = r.FormValue("match")
 		data["corp"] = tournament.Player(match.Corp).Name
 		data["runner"] = tournament.Player(match.Runner).Name
+		if cut {
+			data["cut"] = "cut"
+		}
 
 		if match.Game.Concluded {
-			if match.Game.CorpWin {
+			if match.Game.Forfeit && match.Game.CorpWin {
+				data["runnerNoShow"] = "runnerNoShow"
+			} else if match.Game.Forfeit {
+				data["corpNoShow"] = "corpNoShow"
+			} else if match.Game.GameLoss && match.Game.CorpWin {
+				data["runnerGameLoss"] = "runnerGameLoss"
+			} else if match.Game.GameLoss {
+				data["corpGameLoss"] = "corpGameLoss"
+			} else if match.Game.CorpWin {
 				data["corpWin"] = "corpWin"
 			} else if match.Game.RunnerWin {
 				data["runnerWin"] = "runnerWin"
+			} else if match.Game.IntentionalDraw {
+				data["intentionalDraw"] = "intentionalDraw"
+			} else if match.Game.DoubleLoss {
+				data["doubleLoss"] = "doubleLoss"
 			} else {
 				data["tie"] = "tie"
 			}
+			data["ruling"] = match.Game.Ruling
 
 			if match.Game.ModifiedWin {
 				data["timed"] = "timed"
 			}
 		}
+		if g := match.SecondGame; g != nil {
+			data["twoGames"] = "twoGames"
+			if g.Concluded {
+				if g.GameLoss && g.CorpWin {
+					data["runnerGameLoss2"] = "runnerGameLoss2"
+				} else if g.GameLoss {
+					data["corpGameLoss2"] = "corpGameLoss2"
+				} else if g.CorpWin {
+					data["corpWin2"] = "corpWin2"
+				} else if g.RunnerWin {
+					data["runnerWin2"] = "runnerWin2"
+				} else if g.DoubleLoss {
+					data["doubleLoss2"] = "doubleLoss2"
+				} else {
+					data["tie2"] = "tie2"
+				}
+				if g.Ruling != "" {
+					data["ruling"] = g.Ruling
+				}
+
+				if g.ModifiedWin {
+					data["timed2"] = "timed2"
+				}
+			}
+		}
 		e := applyTemplate(w, recordMatchTemplate, data)
 		if e != nil {
-			seeOther(w, "/matches")
+			seeOther(w, backTo)
+		}
+	}
+}
+
+func isPenaltyResult(result string) bool {
+	return result == "double-loss" || result == "corp-game-loss" || result == "runner-game-loss"
+}
+
+// recordGame records a result from the record result form.
+func recordGame(g *Game, result string, timed bool, ruling string) {
+	switch result {
+	case "corp":
+		g.RecordResult(g.Corp, timed)
+	case "runner":
+		g.RecordResult(g.Runner, timed)
+	case "double-loss":
+		g.RecordDoubleLoss(ruling)
+	case "corp-game-loss":
+		g.RecordGameLoss(g.Corp, ruling)
+	case "runner-game-loss":
+		g.RecordGameLoss(g.Runner, ruling)
+	default:
+		g.RecordResult(NoPlayer, timed)
+	}
+}
+
+func cut(w http.ResponseWriter, r *http.Request) {
+	if tournament.Cut == nil {
+		data := map[string]string{}
+		players := len(tournament.activePlayers())
+		for _, size := range []int{4, 8, 16} {
+			if size <= players {
+				data[fmt.Sprintf("top%d", size)] = "ok"
+			}
+		}
+		applyTemplate(w, startCutTemplate, data)
+	} else {
+		applyTemplate(w, cutTemplate, tournament.Cut)
+	}
+}
+
+func startCut(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		size, _ := strconv.Atoi(r.FormValue("size"))
+		doubleElim := r.FormValue("format") == "double"
+		dropped, e := tournament.StartCut(size, doubleElim)
+		if e != nil {
+			applyTemplate(w, errorTemplate, e)
+			return
+		}
+		reason := fmt.Sprintf("Started top %d single elimination cut", size)
+		if doubleElim {
+			reason = fmt.Sprintf("Started top %d double elimination cut", size)
+		}
+		if len(dropped) != 0 {
+			saveWrapper(reason + ". " + noShowNote(dropped, nil))
+			applyTemplate(w, noShowsTemplate, noShowData(fmt.Sprintf("Started top %d cut", size), "/cut", dropped, nil))
+			return
+		}
+		saveWrapper(reason)
+	}
+	seeOther(w, "/cut")
+}
+
+func chooseSides(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" && tournament.Cut != nil {
+		matchNum, _ := strconv.Atoi(r.FormValue("match"))
+		corpID, _ := strconv.Atoi(r.FormValue("corp"))
+		e := tournament.Cut.ChooseSides(matchNum, PlayerID(corpID))
+		if e != nil {
+			applyTemplate(w, errorTemplate, e)
+			return
 		}
+		m := tournament.Cut.Match(matchNum)
+		saveWrapper(fmt.Sprintf("Chose sides for cut match %d: %s on Corp, %s on Runner", matchNum, tournament.Player(m.Corp).Name, tournament.Player(m.Runner).Name))
 	}
+	seeOther(w, "/cut")
 }
 
 func saves(w http.ResponseWriter, r *http.Request) {
@@ -292,6 +1072,43 @@ func loadOldSave(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handle registers a TO page, which only answers requests from the computer
+// Excalibur runs on, even when -listen lets other devices connect.
+func handle(pattern string, handler http.HandlerFunc) {
+	handlePublic(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if !fromThisComputer(r) {
+			http.Error(w, "Only the registration and check-in pages can be reached from other devices", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// handlePublic registers a handler that holds the tournament lock while it
+// runs. Pairing reads the tournament in the background without the lock, so
+// nothing can change while it's running: POST requests are refused until
+// it's done.
+func handlePublic(pattern string, handler http.HandlerFunc) {
+	http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		tournamentLock.Lock()
+		defer tournamentLock.Unlock()
+		if r.Method == "POST" && pairing.isRunning() {
+			applyTemplate(w, errorTemplate, errors.New("Pairing is in progress; nothing can be changed until it's done"))
+			return
+		}
+		handler(w, r)
+	})
+}
+
+func fromThisComputer(r *http.Request) bool {
+	host, _, e := net.SplitHostPort(r.RemoteAddr)
+	if e != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func saveWrapper(reason string) error {
 	var e error
 	e = tournament.save(filename, reason)
@@ -308,9 +1125,77 @@ func seeOther(w http.ResponseWriter, l string) {
 	w.WriteHeader(http.StatusSeeOther)
 }
 
+// replayRound pairs a round again using the seed it was recorded with and
+// the tournament as it was just before the round started, and prints whether
+// the pairings come out the same.
+func replayRound(file string, round int) error {
+	headers, e := scanSaveFile(file)
+	if e != nil {
+		return e
+	}
+	var t *Tournament
+	var header saveHeader
+	for _, h := range headers {
+		s := &Tournament{}
+		e = loadSave(s, file, h.Number)
+		if e != nil {
+			return e
+		}
+		if len(s.Rounds) == round && !s.Rounds[round-1].Started {
+			t = s
+			header = h
+		}
+	}
+	if t == nil {
+		return fmt.Errorf("No save of round %d from before it started", round)
+	}
+
+	r := &(t.Rounds[round-1])
+	if r.Seed == 0 {
+		return fmt.Errorf("Round %d has no recorded seed", round)
+	}
+	name := func(p PlayerID) string {
+		if p == NoPlayer {
+			return "BYE"
+		}
+		return t.Player(p).Name
+	}
+	edited, timedOut := r.Edited, r.TimedOut
+	recorded := r.Pairings()
+	r.pair(context.Background(), r.Seed, nil)
+	replayed := r.Pairings()
+
+	fmt.Printf("Round %d was paired with seed %d (save %d: %s)\n", round, r.Seed, header.Number, header.Reason)
+	same := len(recorded) == len(replayed)
+	for i, p := range replayed {
+		fmt.Printf("%d: %s (Corp) vs %s (Runner)\n", i+1, name(p.Corp), name(p.Runner))
+		if i >= len(recorded) || recorded[i] != p {
+			same = false
+		}
+	}
+	if timedOut {
+		fmt.Println("Pairing ran out of time when this round was paired, so the replayed pairings may be better.")
+	}
+	if same {
+		fmt.Println("The replayed pairings are identical to the recorded ones.")
+	} else {
+		fmt.Println("The replayed pairings differ from the recorded ones:")
+		for i, p := range recorded {
+			fmt.Printf("%d: %s (Corp) vs %s (Runner)\n", i+1, name(p.Corp), name(p.Runner))
+		}
+		if edited {
+			fmt.Println("The recorded pairings were changed by hand after pairing.")
+		}
+	}
+	return nil
+}
+
 func main() {
-	if len(os.Args) > 1 {
-		filename = os.Args[1]
+	replay := flag.Int("replay", 0, "pair the given round again from its recorded seed, compare with the saved pairings, and exit")
+	listen := flag.String("listen", "localhost:8080", "address to serve on; use :8080 to let players register from their phones")
+	flag.Parse()
+	if flag.NArg() > 0 {
+		filename = flag.Arg(0)
 	}
 	if filename == "" {
 		fmt.Println("Please specify a save file")
@@ -320,6 +1205,14 @@ func main() {
 		filename = filename + ".excalibur"
 	}
 
+	if *replay != 0 {
+		e := replayRound(filename, *replay)
+		if e != nil {
+			fmt.Println(e)
+		}
+		return
+	}
+
 	// try to load tournament or create save file
 	e := loadOrCreate(&tournament, filename)
 	if e != nil {
@@ -327,19 +1220,29 @@ func main() {
 		return
 	}
 
-	rand.Seed(time.Now().UnixNano())
-
-	http.HandleFunc("/", menu)
-	http.HandleFunc("/players", playerList)
-	http.HandleFunc("/players/add", playerForm)
-	http.HandleFunc("/players/change", changePlayer)
-	http.HandleFunc("/standings", standings)
-	http.HandleFunc("/matches", matches)
-	http.HandleFunc("/rounds", rounds)
-	http.HandleFunc("/recordResult", recordResult)
-	http.HandleFunc("/finishRound", finishRound)
-	http.HandleFunc("/nextRound", startRound)
-	http.HandleFunc("/saves", saves)
-	http.HandleFunc("/load", loadOldSave)
-	http.ListenAndServe("localhost:8080", nil)
+	handle("/", menu)
+	handle("/players", playerList)
+	handle("/players/add", playerForm)
+	handle("/players/change", changePlayer)
+	handle("/standings", standings)
+	handle("/restrictions", restrictions)
+	handle("/adjustments", adjustments)
+	handle("/registrations", registrations)
+	handlePublic("/register", register)
+	handlePublic("/checkin", checkIn)
+	handle("/settings", settings)
+	handle("/matches", matches)
+	handle("/rounds", rounds)
+	handle("/rounds/", explainRound)
+	handle("/recordResult", recordResult)
+	handle("/finishRound", finishRound)
+	handle("/nextRound", startRound)
+	handle("/pairing", pairingStatus)
+	handle("/draft", draft)
+	handle("/cut", cut)
+	handle("/cut/start", startCut)
+	handle("/cut/sides", chooseSides)
+	handle("/saves", saves)
+	handle("/load", loadOldSave)
+	http.ListenAndServe(*listen, nil)
 }
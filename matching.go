@@ -0,0 +1,713 @@
+package main
+
+import (
+	"context"
+	"math/big"
+)
+
+// weightedEdge is an edge between vertices i and j for maxWeightMatching.
+type weightedEdge struct {
+	i, j   int
+	weight *big.Int
+}
+
+// matcher holds the state of a run of maxWeightMatching. Vertices are
+// numbered 0 to nvertex-1, blossoms nvertex to 2*nvertex-1. Endpoint p of an
+// edge k is 2*k for edges[k].i and 2*k+1 for edges[k].j.
+type matcher struct {
+	edges            []weightedEdge
+	twiceWeight      []*big.Int
+	nvertex          int
+	endpoint         []int
+	neighbEnd        [][]int
+	mate             []int
+	label            []int
+	labelEnd         []int
+	inBlossom        []int
+	blossomParent    []int
+	blossomChilds    [][]int
+	blossomBase      []int
+	blossomEndps     [][]int
+	bestEdge         []int
+	blossomBestEdges [][]int
+	unusedBlossoms   []int
+	dualVar          []*big.Int
+	allowEdge        []bool
+	queue            []int
+}
+
+// maxWeightMatching finds the matching of maximum weight among all matchings
+// of maximum cardinality, using Edmonds' blossom algorithm in O(n^3) time. It
+// is a port of Joris van Rantwijk's mwmatching.py. Weights are big.Ints so
+// callers can encode strict priorities between criteria in them.
+//
+// The result maps each vertex to the vertex it is matched with, or -1 if it
+// is unmatched. If ctx is done before the matching is found, complete is
+// false and the result is the better of two complete matchings: the one
+// found by the last finished stage, with the vertices it left unmatched
+// matched greedily, and an entirely greedy one. If
+// progress isn't nil, it's called with the number of edges matched so far
+// and the most there could be.
+func maxWeightMatching(ctx context.Context, edges []weightedEdge, progress func(done, total int)) (mates []int, complete bool) {
+	if len(edges) == 0 {
+		return nil, true
+	}
+
+	m := &matcher{edges: edges}
+	maxWeight := big.NewInt(0)
+	for _, e := range edges {
+		if e.i+1 > m.nvertex {
+			m.nvertex = e.i + 1
+		}
+		if e.j+1 > m.nvertex {
+			m.nvertex = e.j + 1
+		}
+		if e.weight.Cmp(maxWeight) > 0 {
+			maxWeight = e.weight
+		}
+		m.twiceWeight = append(m.twiceWeight, new(big.Int).Lsh(e.weight, 1))
+	}
+	n := m.nvertex
+
+	m.endpoint = make([]int, 2*len(edges))
+	m.neighbEnd = make([][]int, n)
+	for k, e := range edges {
+		m.endpoint[2*k] = e.i
+		m.endpoint[2*k+1] = e.j
+		m.neighbEnd[e.i] = append(m.neighbEnd[e.i], 2*k+1)
+		m.neighbEnd[e.j] = append(m.neighbEnd[e.j], 2*k)
+	}
+
+	m.mate = make([]int, n)
+	m.label = make([]int, 2*n)
+	m.labelEnd = make([]int, 2*n)
+	m.inBlossom = make([]int, n)
+	m.blossomParent = make([]int, 2*n)
+	m.blossomChilds = make([][]int, 2*n)
+	m.blossomBase = make([]int, 2*n)
+	m.blossomEndps = make([][]int, 2*n)
+	m.bestEdge = make([]int, 2*n)
+	m.blossomBestEdges = make([][]int, 2*n)
+	m.dualVar = make([]*big.Int, 2*n)
+	m.allowEdge = make([]bool, len(edges))
+	for v := 0; v < n; v++ {
+		m.mate[v] = -1
+		m.inBlossom[v] = v
+		m.blossomBase[v] = v
+		m.blossomBase[n+v] = -1
+		m.unusedBlossoms = append(m.unusedBlossoms, n+v)
+		m.dualVar[v] = new(big.Int).Set(maxWeight)
+		m.dualVar[n+v] = big.NewInt(0)
+	}
+	for b := 0; b < 2*n; b++ {
+		m.labelEnd[b] = -1
+		m.blossomParent[b] = -1
+	}
+
+	// Each stage either augments the matching by one edge or finds that no
+	// further augmentation is possible.
+	complete = true
+	stageMates := m.mates()
+stages:
+	for stage := 0; stage < n; stage++ {
+		if progress != nil {
+			progress(stage, n/2)
+		}
+		for b := 0; b < 2*n; b++ {
+			m.label[b] = 0
+			m.bestEdge[b] = -1
+			if b >= n {
+				m.blossomBestEdges[b] = nil
+			}
+		}
+		for k := range m.allowEdge {
+			m.allowEdge[k] = false
+		}
+		m.queue = m.queue[:0]
+
+		for v := 0; v < n; v++ {
+			if m.mate[v] == -1 && m.label[m.inBlossom[v]] == 0 {
+				m.assignLabel(v, 1, -1)
+			}
+		}
+
+		augmented := false
+		for {
+			if ctx.Err() != nil {
+				// Stop here and make the best of the last finished stage.
+				complete = false
+				break stages
+			}
+			for len(m.queue) > 0 && !augmented {
+				v := m.queue[len(m.queue)-1]
+				m.queue = m.queue[:len(m.queue)-1]
+
+				for _, p := range m.neighbEnd[v] {
+					k := p / 2
+					w := m.endpoint[p]
+					if m.inBlossom[v] == m.inBlossom[w] {
+						continue
+					}
+					var kSlack *big.Int
+					if !m.allowEdge[k] {
+						kSlack = m.slack(k)
+						if kSlack.Sign() <= 0 {
+							m.allowEdge[k] = true
+						}
+					}
+					if m.allowEdge[k] {
+						if m.label[m.inBlossom[w]] == 0 {
+							m.assignLabel(w, 2, p^1)
+						} else if m.label[m.inBlossom[w]] == 1 {
+							base := m.scanBlossom(v, w)
+							if base >= 0 {
+								m.addBlossom(base, k)
+							} else {
+								m.augmentMatching(k)
+								augmented = true
+								break
+							}
+						} else if m.label[w] == 0 {
+							m.label[w] = 2
+							m.labelEnd[w] = p ^ 1
+						}
+					} else if m.label[m.inBlossom[w]] == 1 {
+						b := m.inBlossom[v]
+						if m.bestEdge[b] == -1 || kSlack.Cmp(m.slack(m.bestEdge[b])) < 0 {
+							m.bestEdge[b] = k
+						}
+					} else if m.label[w] == 0 {
+						if m.bestEdge[w] == -1 || kSlack.Cmp(m.slack(m.bestEdge[w])) < 0 {
+							m.bestEdge[w] = k
+						}
+					}
+				}
+			}
+			if augmented {
+				break
+			}
+
+			// No augmenting path yet, so update the dual variables.
+			deltaType := -1
+			var delta *big.Int
+			deltaEdge := -1
+			deltaBlossom := -1
+
+			for v := 0; v < n; v++ {
+				if m.label[m.inBlossom[v]] == 0 && m.bestEdge[v] != -1 {
+					d := m.slack(m.bestEdge[v])
+					if deltaType == -1 || d.Cmp(delta) < 0 {
+						delta = d
+						deltaType = 2
+						deltaEdge = m.bestEdge[v]
+					}
+				}
+			}
+
+			for b := 0; b < 2*n; b++ {
+				if m.blossomParent[b] == -1 && m.label[b] == 1 && m.bestEdge[b] != -1 {
+					d := m.slack(m.bestEdge[b])
+					d.Rsh(d, 1)
+					if deltaType == -1 || d.Cmp(delta) < 0 {
+						delta = d
+						deltaType = 3
+						deltaEdge = m.bestEdge[b]
+					}
+				}
+			}
+
+			for b := n; b < 2*n; b++ {
+				if m.blossomBase[b] >= 0 && m.blossomParent[b] == -1 && m.label[b] == 2 &&
+					(deltaType == -1 || m.dualVar[b].Cmp(delta) < 0) {
+					delta = m.dualVar[b]
+					deltaType = 4
+					deltaBlossom = b
+				}
+			}
+
+			if deltaType == -1 {
+				// No further improvement possible
+				deltaType = 1
+				delta = new(big.Int).Set(m.dualVar[0])
+				for v := 1; v < n; v++ {
+					if m.dualVar[v].Cmp(delta) < 0 {
+						delta.Set(m.dualVar[v])
+					}
+				}
+				if delta.Sign() < 0 {
+					delta.SetInt64(0)
+				}
+			}
+			delta = new(big.Int).Set(delta)
+
+			for v := 0; v < n; v++ {
+				if m.label[m.inBlossom[v]] == 1 {
+					m.dualVar[v].Sub(m.dualVar[v], delta)
+				} else if m.label[m.inBlossom[v]] == 2 {
+					m.dualVar[v].Add(m.dualVar[v], delta)
+				}
+			}
+			for b := n; b < 2*n; b++ {
+				if m.blossomBase[b] >= 0 && m.blossomParent[b] == -1 {
+					if m.label[b] == 1 {
+						m.dualVar[b].Add(m.dualVar[b], delta)
+					} else if m.label[b] == 2 {
+						m.dualVar[b].Sub(m.dualVar[b], delta)
+					}
+				}
+			}
+
+			if deltaType == 1 {
+				break
+			} else if deltaType == 2 {
+				m.allowEdge[deltaEdge] = true
+				i, j := edges[deltaEdge].i, edges[deltaEdge].j
+				if m.label[m.inBlossom[i]] == 0 {
+					i, j = j, i
+				}
+				m.queue = append(m.queue, i)
+			} else if deltaType == 3 {
+				m.allowEdge[deltaEdge] = true
+				m.queue = append(m.queue, edges[deltaEdge].i)
+			} else if deltaType == 4 {
+				m.expandBlossom(deltaBlossom, false)
+			}
+		}
+
+		if !augmented {
+			break
+		}
+
+		// End of stage; expand all S-blossoms which have dual zero.
+		for b := n; b < 2*n; b++ {
+			if m.blossomParent[b] == -1 && m.blossomBase[b] >= 0 && m.label[b] == 1 && m.dualVar[b].Sign() == 0 {
+				m.expandBlossom(b, true)
+			}
+		}
+		stageMates = m.mates()
+	}
+
+	if complete {
+		return stageMates, true
+	}
+	completeMatching(edges, stageMates)
+	greedy := make([]int, n)
+	for v := range greedy {
+		greedy[v] = -1
+	}
+	completeMatching(edges, greedy)
+	if betterMatching(edges, greedy, stageMates) {
+		return greedy, false
+	}
+	return stageMates, false
+}
+
+// mates returns the vertex each vertex is matched with, or -1.
+func (m *matcher) mates() []int {
+	mates := make([]int, m.nvertex)
+	for v := range mates {
+		if m.mate[v] >= 0 {
+			mates[v] = m.endpoint[m.mate[v]]
+		} else {
+			mates[v] = -1
+		}
+	}
+	return mates
+}
+
+// betterMatching returns whether matching a has more edges than matching b,
+// or the same number with more weight.
+func betterMatching(edges []weightedEdge, a, b []int) bool {
+	countA, weightA := matchingSize(edges, a)
+	countB, weightB := matchingSize(edges, b)
+	if countA != countB {
+		return countA > countB
+	}
+	return weightA.Cmp(weightB) > 0
+}
+
+// matchingSize returns the number of edges in a matching and their total
+// weight.
+func matchingSize(edges []weightedEdge, mates []int) (int, *big.Int) {
+	var count int
+	weight := big.NewInt(0)
+	for _, e := range edges {
+		if mates[e.i] == e.j {
+			count += 1
+			weight.Add(weight, e.weight)
+		}
+	}
+	return count, weight
+}
+
+// completeMatching matches as many unmatched vertices as it can, taking
+// each one in turn and matching it along its heaviest edge to another
+// unmatched vertex.
+func completeMatching(edges []weightedEdge, mates []int) {
+	for v := range mates {
+		if mates[v] != -1 {
+			continue
+		}
+		best := -1
+		for k, e := range edges {
+			w := -1
+			if e.i == v {
+				w = e.j
+			} else if e.j == v {
+				w = e.i
+			}
+			if w < 0 || w == v || mates[w] != -1 {
+				continue
+			}
+			if best == -1 || e.weight.Cmp(edges[best].weight) > 0 {
+				best = k
+			}
+		}
+		if best != -1 {
+			mates[edges[best].i] = edges[best].j
+			mates[edges[best].j] = edges[best].i
+		}
+	}
+}
+
+// slack returns the slack of edge k; this does not work for edges inside blossoms.
+func (m *matcher) slack(k int) *big.Int {
+	s := new(big.Int).Add(m.dualVar[m.edges[k].i], m.dualVar[m.edges[k].j])
+	return s.Sub(s, m.twiceWeight[k])
+}
+
+// blossomLeaves returns the vertices contained in blossom b.
+func (m *matcher) blossomLeaves(b int) []int {
+	if b < m.nvertex {
+		return []int{b}
+	}
+	var leaves []int
+	for _, t := range m.blossomChilds[b] {
+		leaves = append(leaves, m.blossomLeaves(t)...)
+	}
+	return leaves
+}
+
+// childAt indexes a blossom's children the way Python indexes lists, so that
+// negative indices count from the end.
+func childAt(s []int, j int) int {
+	if j < 0 {
+		j += len(s)
+	}
+	return s[j]
+}
+
+func indexOf(s []int, x int) int {
+	for i, y := range s {
+		if y == x {
+			return i
+		}
+	}
+	return -1
+}
+
+// assignLabel labels the top-level blossom containing w with t (1 for S, 2
+// for T), reached through the edge with remote endpoint p.
+func (m *matcher) assignLabel(w, t, p int) {
+	b := m.inBlossom[w]
+	m.label[w], m.label[b] = t, t
+	m.labelEnd[w], m.labelEnd[b] = p, p
+	m.bestEdge[w], m.bestEdge[b] = -1, -1
+	if t == 1 {
+		m.queue = append(m.queue, m.blossomLeaves(b)...)
+	} else if t == 2 {
+		base := m.blossomBase[b]
+		m.assignLabel(m.endpoint[m.mate[base]], 1, m.mate[base]^1)
+	}
+}
+
+// scanBlossom traces back from v and w to find either a new blossom, whose
+// base is returned, or an augmenting path, in which case -1 is returned.
+func (m *matcher) scanBlossom(v, w int) int {
+	var path []int
+	base := -1
+	for v != -1 || w != -1 {
+		b := m.inBlossom[v]
+		if m.label[b]&4 != 0 {
+			base = m.blossomBase[b]
+			break
+		}
+		path = append(path, b)
+		m.label[b] = 5
+		if m.labelEnd[b] == -1 {
+			v = -1
+		} else {
+			v = m.endpoint[m.labelEnd[b]]
+			b = m.inBlossom[v]
+			v = m.endpoint[m.labelEnd[b]]
+		}
+		if w != -1 {
+			v, w = w, v
+		}
+	}
+	for _, b := range path {
+		m.label[b] = 1
+	}
+	return base
+}
+
+// addBlossom constructs a new blossom with the given base, containing edge k
+// which connects a pair of S vertices.
+func (m *matcher) addBlossom(base, k int) {
+	v, w := m.edges[k].i, m.edges[k].j
+	bb := m.inBlossom[base]
+	bv := m.inBlossom[v]
+	bw := m.inBlossom[w]
+
+	b := m.unusedBlossoms[len(m.unusedBlossoms)-1]
+	m.unusedBlossoms = m.unusedBlossoms[:len(m.unusedBlossoms)-1]
+	m.blossomBase[b] = base
+	m.blossomParent[b] = -1
+	m.blossomParent[bb] = b
+
+	var path, endps []int
+	for bv != bb {
+		m.blossomParent[bv] = b
+		path = append(path, bv)
+		endps = append(endps, m.labelEnd[bv])
+		v = m.endpoint[m.labelEnd[bv]]
+		bv = m.inBlossom[v]
+	}
+	path = append(path, bb)
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	for i, j := 0, len(endps)-1; i < j; i, j = i+1, j-1 {
+		endps[i], endps[j] = endps[j], endps[i]
+	}
+	endps = append(endps, 2*k)
+	for bw != bb {
+		m.blossomParent[bw] = b
+		path = append(path, bw)
+		endps = append(endps, m.labelEnd[bw]^1)
+		w = m.endpoint[m.labelEnd[bw]]
+		bw = m.inBlossom[w]
+	}
+	m.blossomChilds[b] = path
+	m.blossomEndps[b] = endps
+
+	m.label[b] = 1
+	m.labelEnd[b] = m.labelEnd[bb]
+	m.dualVar[b] = big.NewInt(0)
+	for _, v := range m.blossomLeaves(b) {
+		if m.label[m.inBlossom[v]] == 2 {
+			// This T-vertex now turns into an S-vertex because it
+			// becomes part of an S-blossom
+			m.queue = append(m.queue, v)
+		}
+		m.inBlossom[v] = b
+	}
+
+	// Compute the least-slack edges to neighbouring S-blossoms.
+	bestEdgeTo := make([]int, 2*m.nvertex)
+	for i := range bestEdgeTo {
+		bestEdgeTo[i] = -1
+	}
+	for _, bv := range path {
+		var nbLists [][]int
+		if m.blossomBestEdges[bv] == nil {
+			for _, v := range m.blossomLeaves(bv) {
+				var nbList []int
+				for _, p := range m.neighbEnd[v] {
+					nbList = append(nbList, p/2)
+				}
+				nbLists = append(nbLists, nbList)
+			}
+		} else {
+			nbLists = [][]int{m.blossomBestEdges[bv]}
+		}
+		for _, nbList := range nbLists {
+			for _, k := range nbList {
+				i, j := m.edges[k].i, m.edges[k].j
+				if m.inBlossom[j] == b {
+					i, j = j, i
+				}
+				bj := m.inBlossom[j]
+				if bj != b && m.label[bj] == 1 &&
+					(bestEdgeTo[bj] == -1 || m.slack(k).Cmp(m.slack(bestEdgeTo[bj])) < 0) {
+					bestEdgeTo[bj] = k
+				}
+			}
+		}
+		m.blossomBestEdges[bv] = nil
+		m.bestEdge[bv] = -1
+	}
+	bestEdges := make([]int, 0)
+	for _, k := range bestEdgeTo {
+		if k != -1 {
+			bestEdges = append(bestEdges, k)
+		}
+	}
+	m.blossomBestEdges[b] = bestEdges
+	m.bestEdge[b] = -1
+	for _, k := range bestEdges {
+		if m.bestEdge[b] == -1 || m.slack(k).Cmp(m.slack(m.bestEdge[b])) < 0 {
+			m.bestEdge[b] = k
+		}
+	}
+}
+
+// expandBlossom expands the given top-level blossom.
+func (m *matcher) expandBlossom(b int, endStage bool) {
+	for _, s := range m.blossomChilds[b] {
+		m.blossomParent[s] = -1
+		if s < m.nvertex {
+			m.inBlossom[s] = s
+		} else if endStage && m.dualVar[s].Sign() == 0 {
+			m.expandBlossom(s, endStage)
+		} else {
+			for _, v := range m.blossomLeaves(s) {
+				m.inBlossom[v] = s
+			}
+		}
+	}
+
+	if !endStage && m.label[b] == 2 {
+		// This T-blossom is being expanded in the middle of a stage, so
+		// its children have to be relabeled.
+		childs := m.blossomChilds[b]
+		endps := m.blossomEndps[b]
+		entryChild := m.inBlossom[m.endpoint[m.labelEnd[b]^1]]
+		j := indexOf(childs, entryChild)
+		var jStep, endpTrick int
+		if j&1 != 0 {
+			j -= len(childs)
+			jStep = 1
+			endpTrick = 0
+		} else {
+			jStep = -1
+			endpTrick = 1
+		}
+
+		// Move along the blossom until we get to the base.
+		p := m.labelEnd[b]
+		for j != 0 {
+			m.label[m.endpoint[p^1]] = 0
+			m.label[m.endpoint[childAt(endps, j-endpTrick)^endpTrick^1]] = 0
+			m.assignLabel(m.endpoint[p^1], 2, p)
+			m.allowEdge[childAt(endps, j-endpTrick)/2] = true
+			j += jStep
+			p = childAt(endps, j-endpTrick) ^ endpTrick
+			m.allowEdge[p/2] = true
+			j += jStep
+		}
+
+		// Relabel the base T-sub-blossom without stepping through to its mate.
+		bv := childAt(childs, j)
+		m.label[m.endpoint[p^1]], m.label[bv] = 2, 2
+		m.labelEnd[m.endpoint[p^1]], m.labelEnd[bv] = p, p
+		m.bestEdge[bv] = -1
+
+		// Continue along the blossom until we get back to the entry child.
+		j += jStep
+		for childAt(childs, j) != entryChild {
+			bv := childAt(childs, j)
+			if m.label[bv] == 1 {
+				j += jStep
+				continue
+			}
+			v := -1
+			for _, v = range m.blossomLeaves(bv) {
+				if m.label[v] != 0 {
+					break
+				}
+			}
+			if m.label[v] != 0 {
+				m.label[v] = 0
+				m.label[m.endpoint[m.mate[m.blossomBase[bv]]]] = 0
+				m.assignLabel(v, 2, m.labelEnd[v])
+			}
+			j += jStep
+		}
+	}
+
+	m.label[b] = -1
+	m.labelEnd[b] = -1
+	m.blossomChilds[b] = nil
+	m.blossomEndps[b] = nil
+	m.blossomBase[b] = -1
+	m.blossomBestEdges[b] = nil
+	m.bestEdge[b] = -1
+	m.unusedBlossoms = append(m.unusedBlossoms, b)
+}
+
+// augmentBlossom swaps matched and unmatched edges over an alternating path
+// through blossom b between vertex v and the base vertex.
+func (m *matcher) augmentBlossom(b, v int) {
+	t := v
+	for m.blossomParent[t] != b {
+		t = m.blossomParent[t]
+	}
+	if t >= m.nvertex {
+		m.augmentBlossom(t, v)
+	}
+
+	childs := m.blossomChilds[b]
+	endps := m.blossomEndps[b]
+	i := indexOf(childs, t)
+	j := i
+	var jStep, endpTrick int
+	if i&1 != 0 {
+		j -= len(childs)
+		jStep = 1
+		endpTrick = 0
+	} else {
+		jStep = -1
+		endpTrick = 1
+	}
+
+	for j != 0 {
+		j += jStep
+		t = childAt(childs, j)
+		p := childAt(endps, j-endpTrick) ^ endpTrick
+		if t >= m.nvertex {
+			m.augmentBlossom(t, m.endpoint[p])
+		}
+		j += jStep
+		t = childAt(childs, j)
+		if t >= m.nvertex {
+			m.augmentBlossom(t, m.endpoint[p^1])
+		}
+		m.mate[m.endpoint[p]] = p ^ 1
+		m.mate[m.endpoint[p^1]] = p
+	}
+
+	// Rotate the list of children so that the new base is at the front.
+	m.blossomChilds[b] = append(append([]int(nil), childs[i:]...), childs[:i]...)
+	m.blossomEndps[b] = append(append([]int(nil), endps[i:]...), endps[:i]...)
+	m.blossomBase[b] = m.blossomBase[m.blossomChilds[b][0]]
+}
+
+// augmentMatching swaps matched and unmatched edges over an alternating path
+// between two single vertices, through edge k.
+func (m *matcher) augmentMatching(k int) {
+	ends := [2][2]int{{m.edges[k].i, 2*k + 1}, {m.edges[k].j, 2 * k}}
+	for _, e := range ends {
+		s, p := e[0], e[1]
+		for {
+			bs := m.inBlossom[s]
+			if bs >= m.nvertex {
+				m.augmentBlossom(bs, s)
+			}
+			m.mate[s] = p
+			if m.labelEnd[bs] == -1 {
+				// Reached single vertex; stop.
+				break
+			}
+			t := m.endpoint[m.labelEnd[bs]]
+			bt := m.inBlossom[t]
+			s = m.endpoint[m.labelEnd[bt]]
+			j := m.endpoint[m.labelEnd[bt]^1]
+			if bt >= m.nvertex {
+				m.augmentBlossom(bt, j)
+			}
+			m.mate[j] = m.labelEnd[bt]
+			p = m.labelEnd[bt] ^ 1
+		}
+	}
+}
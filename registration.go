@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Registration is an entry submitted by a player, waiting for the TO to
+// approve it.
+type Registration struct {
+	Name   string
+	Corp   string
+	Runner string
+}
+
+// RegistrationOpen returns whether players can still register themselves and
+// check in, which they can until the first round is paired. The caller must
+// also close it while the first round is being paired.
+func (t *Tournament) RegistrationOpen() bool {
+	return len(t.Rounds) == 0
+}
+
+// PlayerNamed returns the player with the given name, ignoring case, or nil if
+// there isn't one.
+func (t *Tournament) PlayerNamed(name string) *Player {
+	for i := range t.Players {
+		if strings.EqualFold(t.Players[i].Name, strings.TrimSpace(name)) {
+			return &(t.Players[i])
+		}
+	}
+	return nil
+}
+
+// Register records a player's own entry for the TO to approve. If the TO has
+// already entered a player with the same name, the entry can only be merged
+// into that player.
+func (t *Tournament) Register(name, corp, runner string) error {
+	name = strings.TrimSpace(name)
+	if !t.RegistrationOpen() {
+		return errors.New("Registration has closed")
+	}
+	if name == "" {
+		return errors.New("Player name cannot be blank")
+	}
+	for _, reg := range t.Registrations {
+		if strings.EqualFold(reg.Name, name) {
+			return errors.New("Someone has already registered with that name")
+		}
+	}
+	t.Registrations = append(t.Registrations, Registration{name, strings.TrimSpace(corp), strings.TrimSpace(runner)})
+	return nil
+}
+
+func (t *Tournament) registration(i int) (Registration, error) {
+	if i < 0 || i >= len(t.Registrations) {
+		return Registration{}, errors.New("No such registration")
+	}
+	return t.Registrations[i], nil
+}
+
+func (t *Tournament) removeRegistration(i int) {
+	t.Registrations = append(t.Registrations[:i], t.Registrations[i+1:]...)
+}
+
+// ApproveRegistration adds the i-th registration as a new player.
+func (t *Tournament) ApproveRegistration(i int) error {
+	reg, e := t.registration(i)
+	if e != nil {
+		return e
+	}
+	if p := t.PlayerNamed(reg.Name); p != nil {
+		return fmt.Errorf("%s has already been entered as a player; merge the registration into them instead", p.Name)
+	}
+	e = t.AddPlayer(reg.Name, reg.Corp, reg.Runner)
+	if e != nil {
+		return e
+	}
+	t.removeRegistration(i)
+	return nil
+}
+
+// MergeRegistration uses the i-th registration to fill in the IDs of a
+// player who's already entered, e.g. one the TO typed in or who registered
+// twice. The player keeps their name.
+func (t *Tournament) MergeRegistration(i int, p PlayerID) error {
+	reg, e := t.registration(i)
+	if e != nil {
+		return e
+	}
+	player := t.Player(p)
+	if player == nil {
+		return errors.New("No such player")
+	}
+	if reg.Corp != "" {
+		player.Corp = reg.Corp
+	}
+	if reg.Runner != "" {
+		player.Runner = reg.Runner
+	}
+	t.removeRegistration(i)
+	return nil
+}
+
+// RejectRegistration throws away the i-th registration.
+func (t *Tournament) RejectRegistration(i int) error {
+	if _, e := t.registration(i); e != nil {
+		return e
+	}
+	t.removeRegistration(i)
+	return nil
+}
+
+// CheckIn records whether a player is at the venue and ready to play.
+func (t *Tournament) CheckIn(p PlayerID, checkedIn bool) error {
+	player := t.Player(p)
+	if player == nil {
+		return errors.New("No such player")
+	}
+	if !t.RegistrationOpen() {
+		return errors.New("Check-in has closed")
+	}
+	player.CheckedIn = checkedIn
+	if checkedIn && player.CheckInDropped {
+		// they were dropped for not checking in before the first round's
+		// pairings were thrown away
+		t.ReAddPlayer(p)
+	}
+	return nil
+}
+
+// dropAbsentPlayers drops everyone who hasn't checked in, if the tournament
+// requires check-in. It's done just before the first round is paired.
+func (t *Tournament) dropAbsentPlayers() {
+	if !t.RequireCheckIn {
+		return
+	}
+	for i := range t.Players {
+		p := &(t.Players[i])
+		if !p.Dropped && !p.CheckedIn {
+			p.Dropped = true
+			p.CheckInDropped = true
+		}
+	}
+}
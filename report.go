@@ -0,0 +1,151 @@
+package main
+
+import "fmt"
+
+// PairingReport keeps the pairing engine's view of a round, so that players
+// can be told why they were paired the way they were. It reflects the
+// standings and match history from just before the round.
+type PairingReport struct {
+	Profile    string   // name of the pairing profile used
+	Criteria   []string // names of the criteria considered, most important first
+	Rematches  []int    // Rematches[i] = number of pairs matched for the i-th time
+	Restricted int      // number of pairs who asked not to play each other
+	GroupDiffs []int    // GroupDiffs[i] = number of pairs matched across i score groups
+	SideDiffs  []int    // SideDiffs[i] = number of players with a side diff of i after the round
+	Streaks    []int    // Streaks[i] = number of players with a streak of i after the round
+	Matches    []MatchReport
+	Bye        *ByeReport `json:",omitempty"`
+}
+
+// MatchReport is the pairing engine's view of one match. Sides and players
+// are in the same order as in the match's pairing.
+type MatchReport struct {
+	Rematch    int    // number of times these players had already played
+	Restricted bool   // whether these players asked not to play each other
+	GroupDiff  int    // how many score groups apart the players were
+	SideDiffs  [2]int // each player's side diff after the round
+	Streaks    [2]int // how many times in a row each player will have played the same side
+	EarnedBye  bool   // whether this is an earned bye, which the pairing engine didn't choose
+}
+
+// ByeReport explains who got the bye.
+type ByeReport struct {
+	Player       PlayerID
+	Prestige     int
+	PreviousByes int
+	LowerPlayers []ByeCandidate // players with lower scores, who could have had it instead
+}
+
+// ByeCandidate is a player who could have had the bye but didn't.
+type ByeCandidate struct {
+	Player       PlayerID
+	Prestige     int
+	PreviousByes int
+}
+
+var tierNames = map[int]string{
+	rematchTier:      "Rematches and repeat byes",
+	restrictionTier:  "Teammates and players who asked to avoid each other",
+	byeTier:          "Score of player with the bye",
+	sideDiffTier:     "Side differences of three or more",
+	streakTier:       "Streaks of three or more on the same side",
+	groupDiffTier:    "Matches across score groups",
+	mildSideDiffTier: "Side differences of two",
+	mildStreakTier:   "Playing the same side twice in a row",
+}
+
+// pairingReport works out the report for the given pairings, which must be
+// made before the round is played. Earned byes are listed but don't count
+// against the round.
+func (t *Tournament) pairingReport(pairings []Pairing, tiers []int, round int) *PairingReport {
+	report := &PairingReport{Profile: t.Profile().Name}
+	for _, tier := range tiers {
+		report.Criteria = append(report.Criteria, tierNames[tier])
+	}
+
+	var g roundGoodness
+	for _, p := range pairings {
+		d := t.pairingEffects(p.Corp, p.Runner)
+		if !hasTier(tiers, restrictionTier) {
+			// restrictions have been lifted for this round
+			d.restricted = false
+		}
+		if d.isBye && t.earnedBye(p.Corp, round) {
+			report.Matches = append(report.Matches, MatchReport{
+				SideDiffs: d.sideDiffs,
+				Streaks:   d.streaks,
+				EarnedBye: true,
+			})
+			continue
+		}
+		g.addPairing(d)
+		report.Matches = append(report.Matches, MatchReport{
+			Rematch:    d.rematch,
+			Restricted: d.restricted,
+			GroupDiff:  d.groupDiff,
+			SideDiffs:  d.sideDiffs,
+			Streaks:    d.streaks,
+		})
+
+		if d.isBye {
+			bye := &ByeReport{Player: p.Corp, Prestige: d.byePrestige, PreviousByes: len(t.Player(p.Corp).Byes)}
+			for _, other := range t.activePlayers() {
+				o := t.Player(other)
+				if o.Prestige < d.byePrestige && !t.earnedBye(other, round) {
+					bye.LowerPlayers = append(bye.LowerPlayers, ByeCandidate{other, o.Prestige, len(o.Byes)})
+				}
+			}
+			report.Bye = bye
+		}
+	}
+	report.Rematches = g.rematches
+	report.Restricted = g.restricted
+	report.GroupDiffs = g.groupDiffs
+	for _, tier := range sideTiers {
+		if hasTier(tiers, tier) {
+			// sides were considered
+			report.SideDiffs = g.sideDiffs
+			report.Streaks = g.streaks
+		}
+	}
+
+	return report
+}
+
+// MatchReport returns the report for the match with the given number.
+func (r *PairingReport) MatchReport(number int) *MatchReport {
+	if number < 1 || number > len(r.Matches) {
+		return nil
+	}
+	return &(r.Matches[number-1])
+}
+
+// Problems describes everything about the round that the pairing engine
+// would have avoided if it could.
+func (r *PairingReport) Problems() []string {
+	var problems []string
+	for i, n := range r.Rematches {
+		if i > 0 && n > 0 {
+			problems = append(problems, fmt.Sprintf("%d pairings between players who had already met %d times, or repeat byes", n, i))
+		}
+	}
+	if r.Restricted > 0 {
+		problems = append(problems, fmt.Sprintf("%d pairings between teammates or players who asked to avoid each other", r.Restricted))
+	}
+	for i, n := range r.GroupDiffs {
+		if i > 0 && n > 0 {
+			problems = append(problems, fmt.Sprintf("%d matches between players %d score groups apart", n, i))
+		}
+	}
+	for i, n := range r.SideDiffs {
+		if i > 1 && n > 0 {
+			problems = append(problems, fmt.Sprintf("%d players who will have played one side %d more times than the other", n, i))
+		}
+	}
+	for i, n := range r.Streaks {
+		if i > 1 && n > 0 {
+			problems = append(problems, fmt.Sprintf("%d players who will have played the same side %d times in a row", n, i))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestPairingReport(t *testing.T) {
+	tn := playRandomRounds(5, 2)
+	r := tn.Rounds[1]
+	if r.Report == nil {
+		t.Fatal("No pairing report for round 2")
+	}
+	if len(r.Report.Matches) != len(r.Matches) {
+		t.Error("Expected", len(r.Matches), "match reports, got", len(r.Report.Matches))
+	}
+	if r.Report.Bye == nil {
+		t.Fatal("No bye report for round with odd number of players")
+	}
+	for _, m := range r.Matches {
+		if m.IsBye() && m.Corp != r.Report.Bye.Player {
+			t.Error("Bye report is for player", r.Report.Bye.Player, "but bye went to", m.Corp)
+		}
+	}
+	for _, c := range r.Report.Bye.LowerPlayers {
+		if c.Prestige >= r.Report.Bye.Prestige {
+			t.Error("Player with", c.Prestige, "prestige listed as lower than bye player with", r.Report.Bye.Prestige)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import "fmt"
+
+// Scoring is how much prestige each result is worth in one game. In two-game
+// rounds, each game is scored separately and a bye is worth two byes.
+type Scoring struct {
+	Key             string
+	Name            string
+	Win             int
+	ModifiedWin     int // a win on agenda points when time is called
+	Tie             int
+	IntentionalDraw int // unless the tournament sets its own prestige for IDs
+	Loss            int
+	Bye             int
+}
+
+var scoringSchemes = []Scoring{
+	{Key: "standard", Name: "Standard", Win: 3, ModifiedWin: 2, Tie: 1, IntentionalDraw: 1, Loss: 0, Bye: 3},
+	{Key: "nsg", Name: "NSG", Win: 3, ModifiedWin: 3, Tie: 1, IntentionalDraw: 1, Loss: 0, Bye: 3},
+	{Key: "ffg", Name: "Legacy FFG", Win: 2, ModifiedWin: 1, Tie: 0, IntentionalDraw: 0, Loss: 0, Bye: 2},
+}
+
+var defaultScoring = scoringSchemes[0]
+
+// Description lists the prestige for each result.
+func (s Scoring) Description() string {
+	return fmt.Sprintf("win %d, timed win %d, tie %d, intentional draw %d, loss %d, bye %d", s.Win, s.ModifiedWin, s.Tie, s.IntentionalDraw, s.Loss, s.Bye)
+}
+
+// Scoring returns the tournament's scoring scheme.
+func (t *Tournament) Scoring() Scoring {
+	for _, s := range scoringSchemes {
+		if s.Key == t.ScoringScheme {
+			return s
+		}
+	}
+	return defaultScoring
+}
+
+func knownScoring(key string) bool {
+	for _, s := range scoringSchemes {
+		if s.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package main
+
+// PlayerStats is a player's record after some number of rounds, worked out
+// from the recorded results alone.
+type PlayerStats struct {
+	Player          PlayerID
+	Prestige        int // including adjustments
+	Adjustment      int // total of the adjustments counted in Prestige
+	PrestigeAvg     float64
+	SoS             float64
+	XSoS            float64
+	FinishedMatches []MatchID
+	Byes            []MatchID // byes and forfeit wins, which rule out another bye
+	MissedRounds    int       // rounds missed by a late entrant that count as losses or half-byes
+	CorpGames       int
+	RunnerGames     int
+	Streak          int // games in a row on the same side, positive for corp and negative for runner
+
+	OpponentPrestige       int     // total prestige of all opponents
+	MedianOpponentPrestige int     // the same, leaving out the best and worst opponents
+	SideSoS                float64 // SoS averaged over corp and runner games
+	Tiebreak               float64 // the player's recorded random tiebreak
+
+	tied int // the standing of the best player they're tied with, counting from 0
+}
+
+// SideDiff returns how many more corp games than runner games the player has
+// played.
+func (s PlayerStats) SideDiff() int {
+	return s.CorpGames - s.RunnerGames
+}
+
+// Standings is the state of the swiss rounds after a number of rounds.
+type Standings struct {
+	Tournament  *Tournament
+	Round       int           // number of rounds counted
+	Players     []PlayerStats // in standings order
+	Adjustments []Adjustment  // adjustments counted
+	Tiebreakers []Tiebreaker  // used after prestige, most important first
+	index       map[PlayerID]int
+}
+
+// Player returns the given player's stats, or nil if there's no such player.
+func (s *Standings) Player(p PlayerID) *PlayerStats {
+	i, ok := s.index[p]
+	if !ok {
+		return nil
+	}
+	return &(s.Players[i])
+}
+
+// StandingsAfter works out the standings from the finished rounds up to and
+// including the given round, and the adjustments made up to then. It doesn't
+// change the tournament.
+func (t *Tournament) StandingsAfter(round int) *Standings {
+	if round > len(t.Rounds) {
+		round = len(t.Rounds)
+	}
+	s := &Standings{Tournament: t, Round: round, Tiebreakers: t.TiebreakerChain(), index: make(map[PlayerID]int)}
+	for _, p := range t.Standings {
+		s.index[p] = len(s.Players)
+		s.Players = append(s.Players, PlayerStats{Player: p, Tiebreak: t.Player(p).Tiebreak})
+	}
+
+	scoring := t.Scoring()
+	for _, r := range t.Rounds[:round] {
+		if !r.Finished {
+			continue
+		}
+		for _, m := range r.Matches {
+			s.countMatch(MatchID{r.Number, m.Number}, m, scoring)
+		}
+	}
+	for i := range s.Players {
+		s.Players[i].countMissedRounds(t, round, scoring)
+	}
+	for _, a := range t.Adjustments {
+		if a.Round <= round {
+			p := s.Player(a.Player)
+			p.Prestige += a.Prestige
+			p.Adjustment += a.Prestige
+			s.Adjustments = append(s.Adjustments, a)
+		}
+	}
+
+	// Note that byes are counted in the prestige average, because
+	// that's what TOME does; so are missed rounds that score anything
+	for i := range s.Players {
+		p := &(s.Players[i])
+		if rounds := len(p.FinishedMatches) + p.MissedRounds; rounds != 0 {
+			p.PrestigeAvg = float64(p.Prestige) / float64(rounds)
+		}
+	}
+	s.opponentAverages(func(o *PlayerStats) float64 { return o.PrestigeAvg }, func(p *PlayerStats, sos float64) { p.SoS = sos })
+	s.opponentAverages(func(o *PlayerStats) float64 { return o.SoS }, func(p *PlayerStats, xSoS float64) { p.XSoS = xSoS })
+	s.countTiebreaks()
+	s.sortPlayers()
+	return s
+}
+
+// countMatch adds a finished match to both players' records.
+func (s *Standings) countMatch(mID MatchID, m Match, scoring Scoring) {
+	for _, id := range []PlayerID{m.Corp, m.Runner} {
+		p := s.Player(id)
+		if p == nil {
+			continue
+		}
+		p.Prestige += m.GetPrestige(id, scoring)
+		p.FinishedMatches = append(p.FinishedMatches, mID)
+		if m.IsBye() || (m.Forfeit && m.GetWinner() == id) {
+			p.Byes = append(p.Byes, mID)
+		}
+		// byes, intentional draws and forfeits don't count towards sides,
+		// since neither player actually played one
+		if m.IsBye() || m.IntentionalDraw || m.Forfeit {
+			continue
+		}
+		p.playSide(m.Corp == id)
+		if m.SecondGame != nil {
+			p.playSide(m.Corp != id)
+		}
+	}
+}
+
+// countMissedRounds scores the finished rounds up to round that a late
+// entrant missed. They have no opponent, so like byes they don't count
+// towards SoS.
+func (p *PlayerStats) countMissedRounds(t *Tournament, round int, scoring Scoring) {
+	player := t.Player(p.Player)
+	if player.MissedAs == MissedNothing {
+		return
+	}
+	for _, r := range t.Rounds[:round] {
+		if !r.Finished || r.Number > player.MissedRounds {
+			continue
+		}
+		points := scoring.Loss
+		if player.MissedAs == MissedHalfBye {
+			points = scoring.Tie
+		}
+		if r.TwoGames() {
+			// a missed round is two games
+			points *= 2
+		}
+		p.Prestige += points
+		p.MissedRounds += 1
+	}
+}
+
+func (p *PlayerStats) playSide(corp bool) {
+	if corp {
+		p.CorpGames += 1
+		if p.Streak > 0 {
+			p.Streak += 1
+		} else {
+			p.Streak = 1
+		}
+	} else {
+		p.RunnerGames += 1
+		if p.Streak < 0 {
+			p.Streak -= 1
+		} else {
+			p.Streak = -1
+		}
+	}
+}
+
+// opponentAverages sets each player's average of a value over the opponents
+// they actually played; byes and forfeits don't count.
+func (s *Standings) opponentAverages(value func(*PlayerStats) float64, set func(*PlayerStats, float64)) {
+	averages := make([]float64, len(s.Players))
+	for i, p := range s.Players {
+		var sum float64
+		var matchCount int
+		for _, mID := range p.FinishedMatches {
+			m := s.Tournament.Match(mID)
+			if !m.IsBye() && !m.Forfeit {
+				sum += value(s.Player(m.GetOpponent(p.Player)))
+				matchCount += 1
+			}
+		}
+		if matchCount != 0 {
+			averages[i] = sum / float64(matchCount)
+		}
+	}
+	for i := range s.Players {
+		set(&(s.Players[i]), averages[i])
+	}
+}
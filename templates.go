@@ -12,9 +12,11 @@ td.winner {font-weight: bold }
 td.corp { border-bottom: 2px solid #0000aa; }
 td.runner { border-bottom: 2px solid #aa0000; }
 li { padding-bottom: 0.4em; }
+p.round-status { color: #555555; }
 </style>
 </head>
 <body>
+{{with roundStatus}}<p class="round-status">{{.}}</p>{{end}}
 {{template "content" .}}
 </body>
 </html>
@@ -23,24 +25,77 @@ li { padding-bottom: 0.4em; }
 const menuTemplate = `<h1>Tournament menu</h1>
 <ul>
 <li><a href="/players">Players</a></li>
+<li><a href="/registrations">Registrations</a> (players register themselves at <a href="/register">/register</a> and check in at <a href="/checkin">/checkin</a>)</li>
 <li><a href="/standings">Standings</a></li>
 <li><a href="/matches">Current Round Matches</a></li>
 <li><a href="/rounds">All rounds</a></li>
+<li><a href="/cut">Top cut</a></li>
 <li><form action="/finishRound" method="POST"><input type="submit" value="Finish round"></form></li>
-<li><form action="/nextRound" method="POST"><input type="submit" value="Start next round"></form></li>
+<li><form action="/nextRound" method="POST"><input type="submit" value="Pair next round"></form></li>
+<li><a href="/settings">Settings</a></li>
 <li><a href="/saves">History/undo</a></li>
 </ul>
 `
 
 const playerListTemplate = `<h1>Players</h1>
 {{if .Players}}<table>
-{{range .Players}}<form action="/players/change" method="POST"><input type="hidden" name="player-id" value="{{.PlayerID}}"><tr><td>{{.Name}}{{if or .Corp .Runner}} ({{.Corp}}{{if and .Corp .Runner}}, {{end}}{{.Runner}}){{end}}</td><td><a href="/players/change?player-id={{.PlayerID}}">edit</a></td><td>{{if .Dropped}}Dropped <input type="submit" name="re-add" value="Re-add">{{else}}<input type="submit" name="drop" value="Drop">{{end}}</td></tr></form>
+{{range .Players}}<form action="/players/change" method="POST"><input type="hidden" name="player-id" value="{{.PlayerID}}"><tr><td>{{.Name}}{{if or .Corp .Runner}} ({{.Corp}}{{if and .Corp .Runner}}, {{end}}{{.Runner}}){{end}}{{if .Team}} [{{.Team}}]{{end}}{{if .MissedRounds}} (joined after round {{.MissedRounds}}){{end}}{{range .Adjustments}}<br><small>{{printf "%+d" .Prestige}} prestige {{if .Round}}in round {{.Round}}{{else}}before round 1{{end}}: {{.Reason}}</small>{{end}}</td><td><a href="/players/change?player-id={{.PlayerID}}">edit</a></td><td>{{if .Dropped}}Dropped{{if .CheckInDropped}} (didn't check in){{end}} <input type="submit" name="re-add" value="Re-add">{{else}}<input type="submit" name="drop" value="Drop">{{end}}</td>{{if and $.RequireCheckIn registrationOpen}}<td>{{if .CheckedIn}}Checked in <input type="submit" name="check-out" value="Undo">{{else}}<input type="submit" name="check-in" value="Check in">{{end}}</td>{{end}}</tr></form>
 {{end}}</table>
 {{end}}
 <p><a href="/players/add">Add player</a></p>
+<p><a href="/restrictions">Pairing restrictions</a></p>
+<p><a href="/adjustments">Prestige adjustments</a></p>
 <p><a href="/">Menu</a></p>
 `
 
+const registrationsTemplate = `<h1>Registrations</h1>
+{{if registrationOpen}}<p>Players can register themselves at <a href="/register">/register</a>{{if .RequireCheckIn}} and check in at <a href="/checkin">/checkin</a>. Players who haven't checked in are dropped when the first round is paired{{end}}.</p>
+{{else}}<p>Registration has closed.</p>
+{{end}}
+{{if .Registrations}}<table>
+<tr><th>Name</th><th>Corp</th><th>Runner</th><th></th></tr>
+{{range $i, $reg := .Registrations}}<tr>
+<td>{{.Name}}{{with $.PlayerNamed .Name}} (already entered as {{.Name}}){{end}}</td><td>{{.Corp}}</td><td>{{.Runner}}</td>
+<td><form action="/registrations" method="POST">
+<input type="hidden" name="registration" value="{{$i}}">
+{{$match := $.PlayerNamed .Name}}{{if not $match}}<input type="submit" name="approve" value="Approve">
+{{end}}{{if $.Players}}<select name="player">{{range $.Players}}<option value="{{.PlayerID}}"{{if $match}}{{if eq .PlayerID $match.PlayerID}} selected{{end}}{{end}}>{{.Name}}</option>{{end}}</select>
+<input type="submit" name="merge" value="Merge into player">{{end}}
+<input type="submit" name="reject" value="Reject">
+</form></td>
+</tr>
+{{end}}</table>
+{{else}}<p>No registrations waiting.</p>
+{{end}}
+<p><a href="/players">Players</a></p>
+<p><a href="/">Menu</a></p>
+`
+
+const registerTemplate = `<h1>Register</h1>
+{{if .closed}}<p>Registration has closed.</p>
+{{else if .done}}<p>Thanks, {{.name}}. You'll be in the tournament once the TO has approved your entry.{{if .checkIn}} Remember to <a href="/checkin">check in</a> before the first round, or you'll be dropped.{{end}}</p>
+{{else}}{{if .error}}<p><strong>Error: {{.error}}</strong></p>{{end}}
+<form action="/register" method="POST">
+<label>Name: <input type="text" name="name" autofocus{{if .name}} value="{{.name}}"{{end}}></label><br>
+<label>Corp: <input type="text" name="corp"{{if .corp}} value="{{.corp}}"{{end}}></label><br>
+<label>Runner: <input type="text" name="runner"{{if .runner}} value="{{.runner}}"{{end}}></label><br>
+<input type="submit" value="Register">
+</form>
+{{end}}`
+
+const checkInTemplate = `<h1>Check in</h1>
+{{if not registrationOpen}}<p>Check-in has closed.</p>
+{{else}}<form action="/checkin" method="POST">
+<label>Name: <select name="player">{{range .Players}}{{if and (not .CheckedIn) (or (not .Dropped) .CheckInDropped)}}<option value="{{.PlayerID}}">{{.Name}}</option>{{end}}{{end}}</select></label>
+<input type="submit" value="Check in">
+</form>
+<p>If your name isn't listed, you've already checked in or the TO hasn't approved your registration yet.</p>
+{{end}}`
+
+const checkedInTemplate = `<h1>Checked in</h1>
+<p>Thanks, {{.name}}. You're checked in.</p>
+`
+
 const savesTemplate = `<h1>Saved tournament states</h1>
 {{if .}}<p>Note that newer states are at the bottom</p>
 <table>
@@ -61,13 +116,93 @@ const savesTemplate = `<h1>Saved tournament states</h1>
 {{end}}
 `
 
-const standingsTemplate = `{{$t := .}}<h1>Standings</h1>
-{{if .Standings}}<table id="standings">
-<tr><th>Player</th><th>Pts</th><th>SoS</th><th>XSoS</th></tr>
-{{range .Standings}}{{$p := ($t.Player .)}}<tr><td>{{$p.Name}}</td><td>{{$p.Prestige}}</td><td>{{printf "%.3f" $p.SoS}}</td><td>{{printf "%.3f" $p.XSoS}}</td></tr>
+const settingsTemplate = `<h1>Tournament settings</h1>
+{{if .error}}<p><strong>Error: {{.error}}</strong></p>{{end}}
+<form action="/settings" method="POST">
+<label>Swiss rounds: <input type="number" name="swiss-rounds" min="0"{{if .swissRounds}} value="{{.swissRounds}}"{{end}}></label><br>
+<label>Pairing restrictions apply for the first <input type="number" name="restriction-rounds" min="0"{{if .restrictionRounds}} value="{{.restrictionRounds}}"{{end}}> rounds (leave blank for every round)</label><br>
+<p>Pairing profile:</p>
+{{range pairingProfiles}}<label><input type="radio" name="profile" value="{{.Key}}"{{if eq .Key $.profile}} checked{{end}}> {{.Name}}</label> &mdash; {{.Description}}<br>
+{{end}}<p>Scoring:</p>
+{{range scoringSchemes}}<label><input type="radio" name="scoring" value="{{.Key}}"{{if eq .Key $.scoring}} checked{{end}}> {{.Name}}</label> &mdash; {{.Description}}<br>
+{{end}}<label>Prestige for an intentional draw: <input type="number" name="id-prestige" min="0"{{if .idPrestige}} value="{{.idPrestige}}"{{end}}></label> (leave blank to use the scoring scheme's)<br>
+<label>Drop players after <input type="number" name="no-show-limit" min="0"{{if .noShowLimit}} value="{{.noShowLimit}}"{{end}}> no-shows (leave blank to never drop them)</label><br>
+<p>Tiebreakers, in order (leave them all blank for SoS then XSoS):</p>
+<label>Use the same tiebreakers as <select name="tiebreak-preset"><option value="">(choose below)</option>{{range tiebreakPresets}}<option value="{{.Key}}">{{.Name}}</option>{{end}}</select></label><br>
+<ol>
+{{range $key := tiebreakerSlots}}<li><select name="tiebreaker"><option value="">(none)</option>{{range tiebreakers}}<option value="{{.Key}}"{{if eq .Key $key}} selected{{end}}>{{.Name}}</option>{{end}}</select></li>
+{{end}}</ol>
+<label>Pairing time limit: <input type="number" name="pairing-time" min="0"{{if .pairingTime}} value="{{.pairingTime}}"{{end}}> seconds (leave blank for {{.defaultPairingTime}})</label><br>
+<label><input type="checkbox" name="require-check-in"{{if .requireCheckIn}} checked{{end}}> Players must check in before the first round (anyone who hasn't is dropped when it's paired)</label><br>
+<label><input type="checkbox" name="two-game-rounds"{{if .twoGames}} checked{{end}}> Two-game rounds (each player plays both sides, prestige is totalled over both games)</label><br>
+<input type="submit" value="Save">
+</form>
+<p>Suggested for {{.players}} active players: {{.suggested}} rounds.</p>
+<p>With the FIDE profile, the last swiss round is paired to keep players within their score groups, even if that means someone plays the same side three times in a row.</p>
+<p><a href="/">Menu</a></p>
+`
+
+const restrictionsTemplate = `{{$t := .}}<h1>Pairing restrictions</h1>
+<p>Players on the same team, and players who've asked to avoid each other, aren't paired against each other unless the only alternative is a rematch. Teams are set on each player's edit page.{{if .RestrictionRounds}} Restrictions apply for the first {{.RestrictionRounds}} rounds.{{end}}</p>
+<h2>Players avoiding each other</h2>
+<table>
+{{range $p := .Players}}{{range .Avoid}}{{if lt $p.PlayerID .}}<tr>
+<td>{{$p.Name}}</td><td>{{($t.Player .).Name}}</td>
+<td><form action="/restrictions" method="POST">
+<input type="hidden" name="a" value="{{$p.PlayerID}}">
+<input type="hidden" name="b" value="{{.}}">
+<input type="submit" name="remove" value="Remove">
+</form></td>
+</tr>{{end}}{{end}}{{end}}
+</table>
+<form action="/restrictions" method="POST">
+<select name="a">{{range .Players}}<option value="{{.PlayerID}}">{{.Name}}</option>{{end}}</select>
+and
+<select name="b">{{range .Players}}<option value="{{.PlayerID}}">{{.Name}}</option>{{end}}</select>
+<input type="submit" name="add" value="Keep apart">
+</form>
+<p><a href="/players">Players</a></p>
+<p><a href="/">Menu</a></p>
+`
+
+const adjustmentsTemplate = `{{$t := .}}<h1>Prestige adjustments</h1>
+<p>Adjustments add or take away prestige outside of match results, e.g. for a judge's penalty or to correct a result that was recorded wrongly. They count towards standings and strength of schedule straight away.</p>
+{{if .Adjustments}}<table>
+<tr><th>Player</th><th>Round</th><th>Prestige</th><th>Reason</th><th></th></tr>
+{{range $i, $a := .Adjustments}}<tr>
+<td>{{($t.Player .Player).Name}}</td><td>{{if .Round}}{{.Round}}{{else}}before 1{{end}}</td><td>{{printf "%+d" .Prestige}}</td><td>{{.Reason}}</td>
+<td><form action="/adjustments" method="POST">
+<input type="hidden" name="adjustment" value="{{$i}}">
+<input type="submit" name="remove" value="Remove">
+</form></td>
+</tr>
+{{end}}</table>
+{{end}}
+<form action="/adjustments" method="POST">
+<label>Player: <select name="player">{{range .Players}}<option value="{{.PlayerID}}">{{.Name}}</option>{{end}}</select></label><br>
+<label>Round: <input type="number" name="round" min="0" max="{{len .Rounds}}" value="{{len .Rounds}}"></label> (0 for before the first round)<br>
+<label>Prestige: <input type="number" name="prestige"></label> (negative to take prestige away)<br>
+<label>Reason: <input type="text" name="reason"></label><br>
+<input type="submit" name="add" value="Adjust">
+</form>
+<p><a href="/players">Players</a></p>
+<p><a href="/">Menu</a></p>
+`
+
+const standingsTemplate = `{{$t := .Tournament}}{{$round := .Round}}<h1>Standings{{if lt .Round (len $t.Rounds)}} after round {{.Round}}{{end}}</h1>
+{{if $t.Rounds}}<p>After round:{{range $t.Rounds}} {{if eq .Number $round}}{{.Number}}{{else}}<a href="/standings?round={{.Number}}">{{.Number}}</a>{{end}}{{end}}</p>{{end}}
+{{if .Players}}<table id="standings">
+<tr><th>Player</th><th>Pts</th>{{range .Tiebreakers}}{{if .Shown}}<th>{{.Name}}</th>{{end}}{{end}}<th>Random tiebreak</th></tr>
+{{range $p := .Players}}<tr><td>{{($t.Player .Player).Name}}</td><td>{{.Prestige}}{{if .Adjustment}}*{{end}}</td>{{range $.Tiebreakers}}{{if .Shown}}<td>{{.Show $p}}</td>{{end}}{{end}}<td>{{printf "%.3f" .Tiebreak}}</td></tr>
 {{end}}
 </table>
 {{end}}
+{{if .Adjustments}}<h2>Prestige adjustments</h2>
+<p>* Prestige includes these adjustments.</p>
+<ul>
+{{range .Adjustments}}<li>{{($t.Player .Player).Name}}: {{printf "%+d" .Prestige}} {{if .Round}}in round {{.Round}}{{else}}before round 1{{end}} ({{.Reason}})</li>
+{{end}}</ul>
+{{end}}
 <p><a href="/">Menu</a></p>
 `
 
@@ -78,12 +213,18 @@ const playerFormTemplate = `<h1>{{if .add}}Add{{else}}Edit{{end}} player</h1>
 {{- if .id}}<input type="hidden" name="player-id" value="{{.id}}">{{end -}}
 <label>Corp: <input type="text" name="corp"{{if .corp}} value="{{.corp}}"{{end}}></label><br>
 <label>Runner: <input type="text" name="runner"{{if .runner}} value="{{.runner}}"{{end}}></label><br>
-<input type="submit" {{if .add}}name="add" value="Add"{{else}}name="edit" value="Change"{{end}}>
+<label>Team: <input type="text" name="team"{{if .team}} value="{{.team}}"{{end}}></label><br>
+<label>Earned byes: <input type="number" name="earned-byes" min="0"{{if .earnedByes}} value="{{.earnedByes}}"{{end}}></label> (byes for this many rounds from the start)<br>
+{{if .missedRounds}}<label>Rounds missed by registering late: {{.missedRounds}}. They count as <select name="missed-as">{{range missedRoundTreatments}}<option value="{{.Key}}"{{if eq .Key $.missedAs}} selected{{end}}>{{.Name}}</option>{{end}}</select></label><br>
+{{end}}{{if .draft}}<p>The next round has already been paired. Reroll it to include a new player, or they'll miss it.</p>
+{{end}}<input type="submit" {{if .add}}name="add" value="Add"{{else}}name="edit" value="Change"{{end}}>
 </form>
 `
 
-const matchesTemplate = `{{$t := .Tournament}}{{$roundNum := .Number}}<h1>Round {{$roundNum}}</h1>
-<table><tr><th>#</th><th>Corp</th><th>Runner</th><th>Result</th></tr>
+const matchesTemplate = `{{$t := .Tournament}}{{$roundNum := .Number}}{{$started := .Started}}<h1>Round {{$roundNum}}{{if not $started}} (not started){{end}}</h1>
+{{if .Outdated}}<p><strong>Results of an earlier round were corrected after this round was paired, so it might have been paired differently.</strong></p>{{end}}
+{{if .Report}}<p><a href="/rounds/{{$roundNum}}/explain">Why these pairings?</a></p>{{end}}
+<table><tr><th>#</th><th>Corp</th><th>Runner</th><th>Result</th>{{if .TwoGames}}<th>Game 2</th>{{end}}</tr>
 {{range .Matches}}
 <tr>
 <th>{{.Number}}</th>
@@ -104,32 +245,143 @@ const matchesTemplate = `{{$t := .Tournament}}{{$roundNum := .Number}}<h1>Round
  {{- if not .IsBye}} bye{{end -}}
 ">
  {{- if .IsBye -}}
-  BYE
+  BYE{{if .EarnedBye}} (earned){{end}}
  {{- else if .Game.Concluded}}
-  {{- if or .Game.CorpWin .Game.RunnerWin}}
-   {{- if .Game.CorpWin -}}
-    Corp win
-   {{- else -}}
-    Runner win
-   {{- end}}
-   {{- if.Game.ModifiedWin}} (time)
-   {{- end}}
-  {{- else -}}
-   Tie
-  {{- end -}}
+  {{- template "gameResult" .Game}}
  {{- end -}}
- {{if not .IsBye}}
-  {{- if .Game.Concluded}} ({{end -}}
+ {{if and $started (not .IsBye)}}
+  {{- if .IsDone}} ({{end -}}
    <a href="/recordResult?round={{$roundNum}}&match={{.Number}}">
-   {{- if .Game.Concluded}}edit{{else}}record{{end -}}
+   {{- if .IsDone}}edit{{else}}record{{end -}}
    </a>
-   {{- if .Game.Concluded}}){{end}}
+   {{- if .IsDone}}){{end}}
   {{- end -}}
 </td>
+{{- if .SecondGame}}
+<td class="result">
+ {{- if .IsBye}}BYE{{else if .SecondGame.Concluded}}{{template "gameResult" .SecondGame}}{{end -}}
+</td>
+{{- end}}
 </tr>
 {{end}}
 </table>
 <p><a href="/">Menu</a></p>
+{{define "gameResult"}}
+ {{- if .Forfeit}}Forfeit ({{if .CorpWin}}Runner{{else}}Corp{{end}} no-show)
+ {{- else if .IntentionalDraw}}ID
+ {{- else if .DoubleLoss}}<span title="{{.Ruling}}">Double loss</span>
+ {{- else if .GameLoss}}<span title="{{.Ruling}}">Game loss for {{if .CorpWin}}Runner{{else}}Corp{{end}}</span>
+ {{- else if or .CorpWin .RunnerWin}}
+  {{- if .CorpWin}}Corp win{{else}}Runner win{{end}}
+  {{- if .ModifiedWin}} (time){{end}}
+ {{- else -}}
+  Tie
+ {{- end -}}
+{{end}}`
+
+const explainTemplate = `{{$t := .Tournament}}{{$r := .Report}}<h1>Round {{.Number}} pairings</h1>
+{{if .Outdated}}<p><strong>Results of an earlier round were corrected after this round was paired, so it might have been paired differently.</strong></p>{{end}}
+{{if .TimedOut}}<p><strong>Pairing ran out of time, so these might not be the best possible pairings.</strong></p>{{end}}
+{{if .Seed}}<p>Paired with random seed {{.Seed}}{{if .Edited}}, then changed by hand{{end}}.</p>{{end}}
+{{if not $r}}<p>No pairing details were recorded for this round.</p>
+{{else}}{{if eq .Number 1}}<p>The first round is paired at random, except that teammates and players who asked to avoid each other are kept apart.</p>
+{{else}}{{with $r.Profile}}<p>Pairing profile: {{.}}</p>
+{{end}}<p>Pairings are chosen by looking at these things, in order. A pairing that's better at something higher up the list is always preferred, no matter how it does on things further down.</p>
+<ol>
+{{range $r.Criteria}}<li>{{.}}</li>
+{{end}}</ol>
+{{end}}
+<h2>Summary</h2>
+{{with $r.Problems}}<ul>
+{{range .}}<li>{{.}}</li>
+{{end}}</ul>
+{{else}}<p>No rematches, score group crossings or side imbalances.</p>
+{{end}}
+{{with $r.Bye}}<h2>Bye</h2>
+<p>{{($t.Player .Player).Name}} got the bye, with {{.Prestige}} prestige and {{.PreviousByes}} previous byes. The bye goes to the player with the lowest score possible.</p>
+{{if .LowerPlayers}}<p>These players had lower scores, but giving one of them the bye would have made the round worse on a more important criterion:</p>
+<ul>
+{{range .LowerPlayers}}<li>{{($t.Player .Player).Name}}: {{.Prestige}} prestige, {{.PreviousByes}} previous byes</li>
+{{end}}</ul>
+{{end}}{{end}}
+<h2>Matches</h2>
+<table><tr><th>#</th><th>Corp</th><th>Runner</th><th>Previous meetings</th><th>Restricted</th><th>Score groups apart</th><th>Side diffs after round</th><th>Same side streaks after round</th></tr>
+{{range $m := .Matches}}{{with $r.MatchReport $m.Number}}
+<tr>
+<th>{{$m.Number}}</th>
+<td>{{($t.Player $m.Game.Pairing.Corp).Name}}</td>
+<td>{{if $m.IsBye}}BYE{{if $m.EarnedBye}} (earned){{end}}{{else}}{{($t.Player $m.Game.Pairing.Runner).Name}}{{end}}</td>
+<td>{{.Rematch}}</td>
+<td>{{if .Restricted}}Yes{{end}}</td>
+<td>{{if not $m.IsBye}}{{.GroupDiff}}{{end}}</td>
+<td>{{index .SideDiffs 0}}{{if not $m.IsBye}}, {{index .SideDiffs 1}}{{end}}</td>
+<td>{{index .Streaks 0}}{{if not $m.IsBye}}, {{index .Streaks 1}}{{end}}</td>
+</tr>{{end}}{{end}}
+</table>
+{{end}}
+<p><a href="/matches">Matches</a></p>
+<p><a href="/">Menu</a></p>
+`
+
+const draftTemplate = `{{$t := .Tournament}}{{$r := .Report}}<h1>Round {{.Number}} (not started)</h1>
+<p>These pairings can still be changed. Nothing is final until the round is started.</p>
+{{if .Outdated}}<p><strong>Results of an earlier round were corrected after this round was paired. Reroll to pair it from the corrected standings.</strong></p>{{end}}
+{{if .TimedOut}}<p><strong>Pairing ran out of time, so these might not be the best possible pairings.</strong></p>{{end}}
+{{with .MissingPlayers}}<p><strong>Not in these pairings: {{range $i, $p := .}}{{if $i}}, {{end}}{{($t.Player $p).Name}}{{end}}. Reroll to include them, or they'll miss this round.</strong></p>{{end}}
+<h2>Summary</h2>
+{{with $r.Problems}}<ul>
+{{range .}}<li>{{.}}</li>
+{{end}}</ul>
+{{else}}<p>No rematches, score group crossings or side imbalances.</p>
+{{end}}
+<p><a href="/rounds/{{.Number}}/explain">Why these pairings?</a></p>
+<table><tr><th>#</th><th>Corp</th><th>Runner</th><th>Previous meetings</th><th>Restricted</th><th>Score groups apart</th><th>Side diffs after round</th><th></th></tr>
+{{range $m := .Matches}}{{with $r.MatchReport $m.Number}}
+<tr>
+<th>{{$m.Number}}</th>
+<td>{{($t.Player $m.Game.Pairing.Corp).Name}}</td>
+<td>{{if $m.IsBye}}BYE{{if $m.EarnedBye}} (earned){{end}}{{else}}{{($t.Player $m.Game.Pairing.Runner).Name}}{{end}}</td>
+<td>{{.Rematch}}</td>
+<td>{{if .Restricted}}Yes{{end}}</td>
+<td>{{if not $m.IsBye}}{{.GroupDiff}}{{end}}</td>
+<td>{{index .SideDiffs 0}}{{if not $m.IsBye}}, {{index .SideDiffs 1}}{{end}}</td>
+<td>{{if not $m.IsBye}}<form action="/draft" method="POST">
+<input type="hidden" name="action" value="flip">
+<input type="hidden" name="match" value="{{$m.Number}}">
+<input type="submit" value="Swap sides">
+</form>{{end}}</td>
+</tr>{{end}}{{end}}
+</table>
+<h2>Swap players</h2>
+<form action="/draft" method="POST">
+<input type="hidden" name="action" value="swap">
+<select name="a">{{template "draftPlayers" .}}</select>
+and
+<select name="b">{{template "draftPlayers" .}}</select>
+<input type="submit" value="Swap">
+</form>
+<h2>Finish up</h2>
+<form action="/draft" method="POST"><input type="hidden" name="action" value="start"><input type="submit" value="Start round"></form>
+<form action="/draft" method="POST"><input type="hidden" name="action" value="reroll"><input type="submit" value="Pair again from scratch"></form>
+<form action="/draft" method="POST"><input type="hidden" name="action" value="discard"><input type="submit" value="Discard pairings"></form>
+<p><a href="/">Menu</a></p>
+{{define "draftPlayers"}}{{$t := .Tournament}}
+{{- range .Matches}}<option value="{{.Game.Pairing.Corp}}">{{($t.Player .Game.Pairing.Corp).Name}}</option>
+{{- if not .IsBye}}<option value="{{.Game.Pairing.Runner}}">{{($t.Player .Game.Pairing.Runner).Name}}</option>{{end}}
+{{- end}}
+{{- end}}`
+
+const pairingTemplate = `<h1>Pairing</h1>
+<meta http-equiv="refresh" content="1">
+<p>Looking for the best pairings{{if ne .total "0"}}: {{.done}} of {{.total}} matches placed{{end}}.</p>
+<p>If this takes more than {{.limit}} seconds, the best pairings found by then are used.</p>
+`
+
+const noShowsTemplate = `<h1>{{.title}}</h1>
+{{if .dropped}}<p>Dropped after {{.limit}} no-shows: {{.dropped}}</p>{{end}}
+{{if .reAdded}}<p>Re-added, since they no longer have {{.limit}} no-shows: {{.reAdded}}</p>{{end}}
+<p>Dropped players can be re-added on the <a href="/players">players page</a>.</p>
+<p><a href="{{.next}}">Continue</a></p>
 `
 
 const noMatchesTemplate = `<h1>Matches</h1>
@@ -142,13 +394,117 @@ const recordMatchTemplate = `<h1>{{if .winner}}Update{{else}}Record{{end}} match
 <form action="{{.recordurl}}" method="POST">
 <input type="hidden" name="round" value="{{.roundNum}}">
 <input type="hidden" name="match" value="{{.matchNum}}">
-<p>Winner:</p>
+{{- if .cut}}<input type="hidden" name="cut" value="cut">{{end}}
+<p>{{if .twoGames}}Game 1 winner{{else}}Winner{{end}}:</p>
 <label><input type="radio" name="winner" value="corp"{{if .corpWin}} checked{{end}}> {{.corp}} (Corp)</label><br>
+{{- if not .cut}}
 <label><input type="radio" name="winner" value="tie"{{if .tie}} checked{{end}}> Tie</label><br>
-<label><input type="radio" name="winner" value="runner"{{if .runnerWin}} checked{{end}}> {{.runner}} (Runner)</label></p>
+{{- end}}
+<label><input type="radio" name="winner" value="runner"{{if .runnerWin}} checked{{end}}> {{.runner}} (Runner)</label><br>
+{{- if not .cut}}
+<label><input type="radio" name="winner" value="id"{{if .intentionalDraw}} checked{{end}}> Intentional draw{{if .twoGames}} (both games){{end}}</label><br>
+<label><input type="radio" name="winner" value="double-loss"{{if .doubleLoss}} checked{{end}}> Double loss (penalty)</label><br>
+<label><input type="radio" name="winner" value="corp-no-show"{{if .corpNoShow}} checked{{end}}> {{.corp}} didn't show up{{if .twoGames}} (both games){{end}}</label><br>
+<label><input type="radio" name="winner" value="runner-no-show"{{if .runnerNoShow}} checked{{end}}> {{.runner}} didn't show up{{if .twoGames}} (both games){{end}}</label><br>
+{{- end}}
+<label><input type="radio" name="winner" value="corp-game-loss"{{if .corpGameLoss}} checked{{end}}> Game loss for {{.corp}} (penalty)</label><br>
+<label><input type="radio" name="winner" value="runner-game-loss"{{if .runnerGameLoss}} checked{{end}}> Game loss for {{.runner}} (penalty)</label></p>
+{{- if not .cut}}
 <p><label><input type="checkbox" name="timed"{{if .timed}} checked{{end}}> Timed/modified win</label></p>
+{{- end}}
+{{- if .twoGames}}
+<p>Game 2 winner:</p>
+<label><input type="radio" name="winner2" value="corp"{{if .corpWin2}} checked{{end}}> {{.runner}} (Corp)</label><br>
+<label><input type="radio" name="winner2" value="tie"{{if .tie2}} checked{{end}}> Tie</label><br>
+<label><input type="radio" name="winner2" value="runner"{{if .runnerWin2}} checked{{end}}> {{.corp}} (Runner)</label><br>
+<label><input type="radio" name="winner2" value="double-loss"{{if .doubleLoss2}} checked{{end}}> Double loss (penalty)</label><br>
+<label><input type="radio" name="winner2" value="corp-game-loss"{{if .corpGameLoss2}} checked{{end}}> Game loss for {{.runner}} (penalty)</label><br>
+<label><input type="radio" name="winner2" value="runner-game-loss"{{if .runnerGameLoss2}} checked{{end}}> Game loss for {{.corp}} (penalty)</label></p>
+<p><label><input type="checkbox" name="timed2"{{if .timed2}} checked{{end}}> Timed/modified win</label></p>
+{{- end}}
+<p><label>Judge ruling (needed for penalties): <input type="text" name="ruling"{{if .ruling}} value="{{.ruling}}"{{end}}></label></p>
 <p><input type="submit" value="Record"></p>
 </form>
 `
 
+const extraRoundTemplate = `<h1>Pair next round</h1>
+<p><strong>Error: {{.}}</strong></p>
+<form action="/nextRound" method="POST">
+<input type="hidden" name="extra" value="extra">
+<input type="submit" value="Pair an extra round anyway">
+</form>
+<p><a href="/">Menu</a></p>
+`
+
+const startCutTemplate = `<h1>Top cut</h1>
+{{if or .top4 .top8 .top16}}<p>Starting the cut finishes the current swiss round. Players are seeded from the standings, skipping dropped players.</p>
+<p>In a single elimination cut, the higher seed chooses their side. In a double elimination cut, the player who has played Corp less often in the cut plays Corp, and the higher seed only chooses if they've both played each side equally often.</p>
+<form action="/cut/start" method="POST">
+<label>Cut size: <select name="size">
+{{- if .top4}}<option value="4">Top 4</option>{{end}}
+{{- if .top8}}<option value="8">Top 8</option>{{end}}
+{{- if .top16}}<option value="16">Top 16</option>{{end -}}
+</select></label><br>
+<label>Format: <select name="format">
+<option value="double">Double elimination</option>
+<option value="single">Single elimination</option>
+</select></label><br>
+<input type="submit" value="Start cut">
+</form>
+{{else}}
+<p>Not enough players for a cut.</p>
+{{end}}
+<p><a href="/">Menu</a></p>
+`
+
+const cutTemplate = `{{$c := .}}{{$t := .Tournament}}<h1>Top {{len .Seeds}} cut</h1>
+{{with $t.Player $c.Winner}}<p>Winner: <strong>{{.Name}}</strong></p>{{end}}
+{{range $round := .RoundNumbers}}<h2>{{$c.RoundName $round}}</h2>
+<table><tr><th>#</th>{{if $c.DoubleElim}}<th>Bracket</th>{{end}}<th>Corp</th><th>Runner</th><th>Result</th></tr>
+{{range $c.Matches}}{{if eq .Round $round}}
+<tr>
+<th>{{.Number}}</th>
+{{- if $c.DoubleElim}}
+<td>{{if .Losers}}Losers{{else if or .GrandFinal .Reset}}Final{{else}}Winners{{end}}</td>
+{{- end}}
+{{- if .SidesChosen}}
+<td class="corp
+ {{- if .Game.CorpWin}} winner{{end -}}
+">{{($t.Player .Game.Pairing.Corp).Name}} ({{$c.Seed .Game.Pairing.Corp}})</td>
+<td class="runner
+ {{- if .Game.RunnerWin}} winner{{end -}}
+">{{($t.Player .Game.Pairing.Runner).Name}} ({{$c.Seed .Game.Pairing.Runner}})</td>
+<td class="result">
+ {{- if .Game.Concluded}}
+  {{- if .Game.CorpWin}}Corp win{{else}}Runner win{{end}} (
+ {{- end -}}
+ <a href="/recordResult?cut=cut&match={{.Number}}">
+ {{- if .Game.Concluded}}edit{{else}}record{{end -}}
+ </a>
+ {{- if .Game.Concluded}}){{end -}}
+</td>
+{{- else if .Ready}}
+<td colspan="2">{{$c.SlotName . 0}} vs {{$c.SlotName . 1}}</td>
+<td>
+<form action="/cut/sides" method="POST">
+<input type="hidden" name="match" value="{{.Number}}">
+{{($t.Player (index .Players 0)).Name}} chooses:
+<button type="submit" name="corp" value="{{index .Players 0}}">Corp</button>
+<button type="submit" name="corp" value="{{index .Players 1}}">Runner</button>
+</form>
+</td>
+{{- else if $c.Skipped .}}
+<td colspan="2">Not needed</td>
+<td></td>
+{{- else}}
+<td colspan="2">{{$c.SlotName . 0}} vs {{$c.SlotName . 1}}</td>
+<td></td>
+{{- end}}
+</tr>
+{{end}}{{end}}
+</table>
+{{end}}
+<p><a href="/">Menu</a></p>
+`
+
 const errorTemplate = `{{if .}}<p><strong>Error: {{.}}</strong></p>{{end}}`
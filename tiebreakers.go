@@ -0,0 +1,319 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Tiebreaker is a way of ranking players who have the same prestige.
+type Tiebreaker struct {
+	Key  string
+	Name string
+	// better returns a positive number if a ranks above b, a negative number
+	// if b ranks above a, or 0 if it can't tell them apart.
+	better func(s *Standings, a, b *PlayerStats) int
+	// scores is used instead of better by tiebreakers that can only rank a
+	// whole group of tied players at once. It returns each player's score
+	// within the group, or nil if the tiebreaker doesn't apply to the group.
+	scores func(s *Standings, tied []*PlayerStats) map[PlayerID]int
+	show   func(p *PlayerStats) string // the value shown in the standings, or nil
+}
+
+// Shown returns whether the tiebreaker has a value to show in the standings;
+// head to head, for instance, only compares pairs of players.
+func (tb Tiebreaker) Shown() bool {
+	return tb.show != nil
+}
+
+// Show returns the player's value for the tiebreaker, as shown in the
+// standings.
+func (tb Tiebreaker) Show(p PlayerStats) string {
+	if tb.show == nil {
+		return ""
+	}
+	return tb.show(&p)
+}
+
+func compareFloats(a, b float64) int {
+	if a > b {
+		return 1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
+
+var tiebreakers = []Tiebreaker{
+	{
+		Key:    "sos",
+		Name:   "SoS",
+		better: func(s *Standings, a, b *PlayerStats) int { return compareFloats(a.SoS, b.SoS) },
+		show:   func(p *PlayerStats) string { return fmt.Sprintf("%.3f", p.SoS) },
+	},
+	{
+		Key:    "xsos",
+		Name:   "XSoS",
+		better: func(s *Standings, a, b *PlayerStats) int { return compareFloats(a.XSoS, b.XSoS) },
+		show:   func(p *PlayerStats) string { return fmt.Sprintf("%.3f", p.XSoS) },
+	},
+	{
+		Key:    "buchholz",
+		Name:   "Extended SoS",
+		better: func(s *Standings, a, b *PlayerStats) int { return a.OpponentPrestige - b.OpponentPrestige },
+		show:   func(p *PlayerStats) string { return fmt.Sprint(p.OpponentPrestige) },
+	},
+	{
+		Key:    "median",
+		Name:   "Median Buchholz",
+		better: func(s *Standings, a, b *PlayerStats) int { return a.MedianOpponentPrestige - b.MedianOpponentPrestige },
+		show:   func(p *PlayerStats) string { return fmt.Sprint(p.MedianOpponentPrestige) },
+	},
+	{
+		// head to head isn't transitive (A can beat B, B beat C and C beat
+		// A), so it ranks whole groups
+		Key:    "h2h",
+		Name:   "Head to head",
+		scores: func(s *Standings, tied []*PlayerStats) map[PlayerID]int { return s.headToHeadScores(tied) },
+	},
+	{
+		Key:    "side-sos",
+		Name:   "Side-balanced SoS",
+		better: func(s *Standings, a, b *PlayerStats) int { return compareFloats(a.SideSoS, b.SideSoS) },
+		show:   func(p *PlayerStats) string { return fmt.Sprintf("%.3f", p.SideSoS) },
+	},
+	{
+		Key:    "byes",
+		Name:   "Fewest byes",
+		better: func(s *Standings, a, b *PlayerStats) int { return len(b.Byes) - len(a.Byes) },
+		show:   func(p *PlayerStats) string { return fmt.Sprint(len(p.Byes)) },
+	},
+	{
+		Key:    "coin",
+		Name:   "Coin flip",
+		better: func(s *Standings, a, b *PlayerStats) int { return compareFloats(a.Tiebreak, b.Tiebreak) },
+		// the recorded tiebreak is always shown
+	},
+}
+
+// TiebreakPreset is a named tiebreaker chain that mirrors other tournament
+// software or rules.
+type TiebreakPreset struct {
+	Key         string
+	Name        string
+	Tiebreakers []string
+}
+
+var tiebreakPresets = []TiebreakPreset{
+	{"cobra", "Cobra", []string{"sos", "xsos"}},
+	{"nrtm", "NRTM", []string{"sos", "xsos", "coin"}},
+	{"fide", "FIDE", []string{"h2h", "median", "buchholz", "coin"}},
+}
+
+var defaultTiebreakers = []string{"sos", "xsos"}
+
+func findTiebreaker(key string) (Tiebreaker, bool) {
+	for _, tb := range tiebreakers {
+		if tb.Key == key {
+			return tb, true
+		}
+	}
+	return Tiebreaker{}, false
+}
+
+func findTiebreakPreset(key string) (TiebreakPreset, bool) {
+	for _, p := range tiebreakPresets {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return TiebreakPreset{}, false
+}
+
+// TiebreakerChain returns the tournament's tiebreakers, most important first.
+func (t *Tournament) TiebreakerChain() []Tiebreaker {
+	keys := t.Tiebreakers
+	if len(keys) == 0 {
+		keys = defaultTiebreakers
+	}
+	var chain []Tiebreaker
+	for _, key := range keys {
+		if tb, ok := findTiebreaker(key); ok {
+			chain = append(chain, tb)
+		}
+	}
+	return chain
+}
+
+// sortPlayers puts the players in standings order. They're sorted on
+// prestige, then each group of players who are still tied is split up by the
+// first tiebreaker, then what's still tied by the next one, and so on. Any
+// remaining ties are broken by the players' recorded tiebreaks and then by
+// registration order, so that no two players are ever tied.
+func (s *Standings) sortPlayers() {
+	players := make([]*PlayerStats, len(s.Players))
+	for i := range s.Players {
+		players[i] = &(s.Players[i])
+	}
+	groups := splitTies(players, func(a, b *PlayerStats) int { return a.Prestige - b.Prestige })
+	for _, tb := range s.Tiebreakers {
+		var split [][]*PlayerStats
+		for _, g := range groups {
+			split = append(split, s.breakTies(tb, g)...)
+		}
+		groups = split
+	}
+
+	var sorted []PlayerStats
+	for _, g := range groups {
+		sort.SliceStable(g, func(i, j int) bool {
+			if g[i].Tiebreak != g[j].Tiebreak {
+				return g[i].Tiebreak > g[j].Tiebreak
+			}
+			return g[i].Player < g[j].Player
+		})
+		group := len(sorted)
+		for _, p := range g {
+			p.tied = group
+			sorted = append(sorted, *p)
+		}
+	}
+	s.Players = sorted
+	for i, p := range s.Players {
+		s.index[p.Player] = i
+	}
+}
+
+// breakTies splits a group of tied players into smaller groups using the
+// given tiebreaker, best first.
+func (s *Standings) breakTies(tb Tiebreaker, tied []*PlayerStats) [][]*PlayerStats {
+	if len(tied) < 2 {
+		return [][]*PlayerStats{tied}
+	}
+	if tb.scores != nil {
+		scores := tb.scores(s, tied)
+		if scores == nil {
+			return [][]*PlayerStats{tied}
+		}
+		return splitTies(tied, func(a, b *PlayerStats) int { return scores[a.Player] - scores[b.Player] })
+	}
+	return splitTies(tied, func(a, b *PlayerStats) int { return tb.better(s, a, b) })
+}
+
+// splitTies sorts players with better, which works like a tiebreaker's, and
+// splits them into groups that better can't tell apart, best first.
+func splitTies(players []*PlayerStats, better func(a, b *PlayerStats) int) [][]*PlayerStats {
+	sort.SliceStable(players, func(i, j int) bool { return better(players[i], players[j]) > 0 })
+	var groups [][]*PlayerStats
+	start := 0
+	for i := 1; i <= len(players); i++ {
+		if i == len(players) || better(players[i-1], players[i]) != 0 {
+			groups = append(groups, players[start:i])
+			start = i
+		}
+	}
+	return groups
+}
+
+// compare returns a positive number if a ranks above b, a negative number if
+// b ranks above a, or 0 if they're tied on prestige and every tiebreaker.
+func (s *Standings) compare(a, b *PlayerStats) int {
+	return b.tied - a.tied
+}
+
+// rank is compare, with any remaining ties broken by the players' recorded
+// tiebreaks and then by registration order, so that no two players are ever
+// tied.
+func (s *Standings) rank(a, b *PlayerStats) int {
+	return s.index[b.Player] - s.index[a.Player]
+}
+
+// headToHead returns how many more times a beat b than b beat a, and whether
+// they've played each other at all.
+func (s *Standings) headToHead(a, b *PlayerStats) (int, bool) {
+	var n int
+	var met bool
+	for _, mID := range a.FinishedMatches {
+		m := s.Tournament.Match(mID)
+		if m.GetOpponent(a.Player) != b.Player {
+			continue
+		}
+		met = true
+		switch m.GetWinner() {
+		case a.Player:
+			n += 1
+		case b.Player:
+			n -= 1
+		}
+	}
+	return n, met
+}
+
+// headToHeadScores scores each of a group of tied players by their results
+// against the others. That's only fair if every one of them has played every
+// other one, so otherwise it returns nil.
+func (s *Standings) headToHeadScores(tied []*PlayerStats) map[PlayerID]int {
+	scores := make(map[PlayerID]int)
+	for _, a := range tied {
+		for _, b := range tied {
+			if a == b {
+				continue
+			}
+			n, met := s.headToHead(a, b)
+			if !met {
+				return nil
+			}
+			scores[a.Player] += n
+		}
+	}
+	return scores
+}
+
+// countTiebreaks works out the tiebreak values that aren't averages of
+// opponents' values.
+func (s *Standings) countTiebreaks() {
+	for i := range s.Players {
+		p := &(s.Players[i])
+		var opponents []int
+		var sideSoS [2]float64
+		var sideCount [2]int
+		for _, mID := range p.FinishedMatches {
+			m := s.Tournament.Match(mID)
+			if m.IsBye() || m.Forfeit {
+				continue
+			}
+			o := s.Player(m.GetOpponent(p.Player))
+			opponents = append(opponents, o.Prestige)
+			for side := range sideSoS {
+				// in two-game rounds, each opponent was played on both sides
+				if m.SecondGame != nil || (side == 0) == (m.Corp == p.Player) {
+					sideSoS[side] += o.PrestigeAvg
+					sideCount[side] += 1
+				}
+			}
+		}
+
+		p.OpponentPrestige = 0
+		for _, o := range opponents {
+			p.OpponentPrestige += o
+		}
+		p.MedianOpponentPrestige = p.OpponentPrestige
+		if len(opponents) >= 3 {
+			sort.Ints(opponents)
+			p.MedianOpponentPrestige -= opponents[0] + opponents[len(opponents)-1]
+		}
+
+		// the average of the SoS over corp games and over runner games, so
+		// that playing one side more often doesn't count
+		var sides int
+		p.SideSoS = 0
+		for side := range sideSoS {
+			if sideCount[side] != 0 {
+				p.SideSoS += sideSoS[side] / float64(sideCount[side])
+				sides += 1
+			}
+		}
+		if sides != 0 {
+			p.SideSoS /= float64(sides)
+		}
+	}
+}
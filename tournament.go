@@ -1,25 +1,63 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"math/rand"
 	"os"
 	"sort"
+	"strings"
+	"time"
 )
 
 type Tournament struct {
-	Name        string
-	Players     []Player
-	Standings   []PlayerID
-	Rounds      []Round
-	SosUpToDate bool
-	ScoreGroups map[int]int
+	Name          string
+	Players       []Player
+	Standings     []PlayerID
+	Rounds        []Round
+	SosUpToDate   bool
+	ScoreGroups   map[int]int
+	SwissRounds   int  // planned number of swiss rounds, or 0 if not set
+	TwoGameRounds bool // whether each swiss round is two games, one on each side
+	Cut           *Cut
+	// pairing restrictions apply for this many rounds, or every round if 0
+	RestrictionRounds int
+	// seconds to spend pairing a round, or 0 for defaultPairingTimeLimit
+	PairingTimeLimit int
+	PairingProfile   string // key of the pairing profile, or "" for the default
+	// players are dropped after this many no-shows, or never if 0
+	NoShowDropLimit int
+	// key of the scoring scheme, or "" for the default
+	ScoringScheme string `json:",omitempty"`
+	// prestige each player gets for an intentional draw, or nil for the
+	// scoring scheme's
+	IDPrestige *int `json:",omitempty"`
+	// keys of the tiebreakers used after prestige, or nil for defaultTiebreakers
+	Tiebreakers []string `json:",omitempty"`
+	// entries players submitted themselves, waiting for the TO
+	Registrations []Registration `json:",omitempty"`
+	// whether players who haven't checked in are dropped before round 1
+	RequireCheckIn bool `json:",omitempty"`
+	// prestige given or taken away outside of match results
+	Adjustments []Adjustment `json:",omitempty"`
+
+	rng       *rand.Rand // source of round seeds and standings tiebreaks
+	standings *Standings // the current standings, worked out by updateStats
+}
+
+// random returns the tournament's random number generator.
+func (t *Tournament) random() *rand.Rand {
+	if t.rng == nil {
+		t.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return t.rng
 }
 
 func (t *Tournament) Player(id PlayerID) *Player {
-	if id == NoPlayer || int(id) > len(t.Players) {
+	if id < 1 || int(id) > len(t.Players) {
 		return nil
 	}
 	return &(t.Players[id-1])
@@ -35,30 +73,320 @@ func (t *Tournament) AddPlayer(Name string, Corp string, Runner string) error {
 		}
 	}
 	var id PlayerID = PlayerID(len(t.Players) + 1)
-	t.Players = append(t.Players, Player{Name: Name, Corp: Corp, Runner: Runner, Tournament: t, PlayerID: id})
+	t.Players = append(t.Players, Player{Name: Name, Corp: Corp, Runner: Runner, Tournament: t, PlayerID: id, Tiebreak: t.random().Float64(), MissedRounds: t.StartedRounds()})
 	t.Standings = append(t.Standings, id)
+	t.SosUpToDate = false
+	return nil
+}
+
+// StartedRounds returns how many swiss rounds have started.
+func (t *Tournament) StartedRounds() int {
+	if t.Draft() != nil {
+		return len(t.Rounds) - 1
+	}
+	return len(t.Rounds)
+}
+
+// How the rounds a late entrant missed count towards their prestige.
+const (
+	MissedNothing = ""         // they don't count at all
+	MissedLoss    = "loss"     // each counts as a loss
+	MissedHalfBye = "half-bye" // each is worth as much as a tie
+)
+
+// MissedRoundTreatment is a choice of how missed rounds count.
+type MissedRoundTreatment struct {
+	Key  string
+	Name string
+}
+
+var missedRoundTreatments = []MissedRoundTreatment{
+	{MissedNothing, "Nothing (not counted)"},
+	{MissedLoss, "Losses"},
+	{MissedHalfBye, "Half-byes (worth a tie)"},
+}
+
+func knownMissedRoundTreatment(key string) bool {
+	for _, m := range missedRoundTreatments {
+		if m.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMissedRounds chooses how the rounds a late entrant missed count.
+func (t *Tournament) SetMissedRounds(p PlayerID, as string) error {
+	player := t.Player(p)
+	if player == nil {
+		return errors.New("No such player")
+	}
+	if !knownMissedRoundTreatment(as) {
+		return errors.New("Unknown treatment for missed rounds")
+	}
+	if player.MissedRounds == 0 {
+		as = MissedNothing
+	}
+	if player.MissedAs != as {
+		player.MissedAs = as
+		t.RecomputeStandings()
+	}
 	return nil
 }
 
+// suggestedSwissRounds gives the recommended number of swiss rounds for the
+// given number of players, per the Netrunner tournament guidelines.
+func suggestedSwissRounds(players int) int {
+	switch {
+	case players <= 8:
+		return 3
+	case players <= 16:
+		return 4
+	case players <= 32:
+		return 5
+	case players <= 64:
+		return 6
+	case players <= 128:
+		return 7
+	case players <= 226:
+		return 8
+	case players <= 409:
+		return 9
+	default:
+		return 10
+	}
+}
+
+// RoundStatus describes how far through the swiss rounds the tournament is.
+func (t *Tournament) RoundStatus() string {
+	if t.Cut != nil {
+		return fmt.Sprintf("Top %d cut", len(t.Cut.Seeds))
+	}
+	if len(t.Rounds) == 0 {
+		if t.SwissRounds != 0 {
+			return fmt.Sprintf("%d swiss rounds planned", t.SwissRounds)
+		}
+		return ""
+	}
+	if d := t.Draft(); d != nil {
+		if t.SwissRounds != 0 {
+			return fmt.Sprintf("Round %d of %d (not started)", d.Number, t.SwissRounds)
+		}
+		return fmt.Sprintf("Round %d (not started)", d.Number)
+	}
+	if t.SwissRounds != 0 {
+		return fmt.Sprintf("Round %d of %d", len(t.Rounds), t.SwissRounds)
+	}
+	return fmt.Sprintf("Round %d", len(t.Rounds))
+}
+
 func (t *Tournament) DropPlayer(p PlayerID) {
 	t.Player(p).Dropped = true
+	t.Player(p).NoShowDropped = false
+	t.Player(p).CheckInDropped = false
 }
 
 func (t *Tournament) ReAddPlayer(p PlayerID) {
 	t.Player(p).Dropped = false
+	t.Player(p).NoShowDropped = false
+	t.Player(p).CheckInDropped = false
 }
 
-func (t *Tournament) NextRound() error {
+// NoShows returns how many finished matches the player forfeited by not
+// showing up.
+func (t *Tournament) NoShows(p PlayerID) int {
+	var n int
+	for _, mID := range t.Player(p).FinishedMatches {
+		m := t.Match(mID)
+		if m.Forfeit && m.GetWinner() != p {
+			n += 1
+		}
+	}
+	return n
+}
+
+// Adjustment is a change to a player's prestige made by a judge, e.g. a
+// penalty or a correction to a misrecorded result.
+type Adjustment struct {
+	Player   PlayerID
+	Round    int // the round it relates to, or 0 for before the first round
+	Prestige int // points added, or taken away if negative
+	Reason   string
+}
+
+// AddAdjustment changes a player's prestige and re-sorts the standings.
+func (t *Tournament) AddAdjustment(a Adjustment) error {
+	p := t.Player(a.Player)
+	if p == nil {
+		return errors.New("No such player")
+	}
+	if a.Prestige == 0 {
+		return errors.New("An adjustment must change the player's prestige")
+	}
+	if a.Round < 0 || a.Round > len(t.Rounds) {
+		return errors.New("No such round")
+	}
+	if strings.TrimSpace(a.Reason) == "" {
+		return errors.New("An adjustment needs a reason")
+	}
+	t.Adjustments = append(t.Adjustments, a)
+	t.RecomputeStandings()
+	return nil
+}
+
+// RemoveAdjustment undoes the i-th adjustment.
+func (t *Tournament) RemoveAdjustment(i int) error {
+	if i < 0 || i >= len(t.Adjustments) {
+		return errors.New("No such adjustment")
+	}
+	t.Adjustments = append(t.Adjustments[:i], t.Adjustments[i+1:]...)
+	t.RecomputeStandings()
+	return nil
+}
+
+// AvoidPairing asks the pairing engine to avoid pairing two players against
+// each other.
+func (t *Tournament) AvoidPairing(a, b PlayerID) error {
+	pa, pb := t.Player(a), t.Player(b)
+	if pa == nil || pb == nil {
+		return errors.New("No such player")
+	}
+	if a == b {
+		return errors.New("A player can't avoid themselves")
+	}
+	if t.avoids(a, b) {
+		return nil
+	}
+	pa.Avoid = append(pa.Avoid, b)
+	pb.Avoid = append(pb.Avoid, a)
+	return nil
+}
+
+// AllowPairing removes a restriction added by AvoidPairing.
+func (t *Tournament) AllowPairing(a, b PlayerID) {
+	remove := func(p *Player, other PlayerID) {
+		if p == nil {
+			return
+		}
+		for i, o := range p.Avoid {
+			if o == other {
+				p.Avoid = append(p.Avoid[:i], p.Avoid[i+1:]...)
+				return
+			}
+		}
+	}
+	remove(t.Player(a), b)
+	remove(t.Player(b), a)
+}
+
+func (t *Tournament) avoids(a, b PlayerID) bool {
+	for _, o := range t.Player(a).Avoid {
+		if o == b {
+			return true
+		}
+	}
+	return false
+}
+
+// restricted returns whether two players have asked not to play each other,
+// either directly or by being on the same team.
+func (t *Tournament) restricted(a, b PlayerID) bool {
+	pa, pb := t.Player(a), t.Player(b)
+	if pa.Team != "" && strings.EqualFold(pa.Team, pb.Team) {
+		return true
+	}
+	return t.avoids(a, b)
+}
+
+var errSwissFinished = errors.New("All planned swiss rounds have been played")
+
+// NextRound finishes the current round and pairs the next one. The new round
+// is a draft whose pairings can be adjusted until it's started. Once the
+// planned number of swiss rounds have been played, it refuses to pair another
+// unless extraRound is set.
+func (t *Tournament) NextRound(extraRound bool) error {
+	return t.NextRoundContext(context.Background(), extraRound, nil)
+}
+
+// NextRoundContext is NextRound with a context that limits how long pairing
+// can take, and an optional function to report progress to.
+func (t *Tournament) NextRoundContext(ctx context.Context, extraRound bool, progress ProgressFunc) error {
+	r, _, e := t.PrepareNextRound(extraRound)
+	if e != nil {
+		return e
+	}
+	r.MakeMatchesContext(ctx, progress)
+	t.Rounds = append(t.Rounds, r)
+	return nil
+}
+
+// PrepareNextRound does everything NextRound does except pairing: it
+// finishes the current round and returns the next one, unpaired. The round
+// isn't added to the tournament until it's paired, so a round that's still
+// being paired is never seen as the draft. Pairing it only reads the
+// tournament. It also returns the players dropped for reaching the no-show
+// limit in the round it finished.
+func (t *Tournament) PrepareNextRound(extraRound bool) (Round, []PlayerID, error) {
+	if t.Cut != nil {
+		return Round{}, nil, errors.New("The cut has already started")
+	}
+	if t.Draft() != nil {
+		return Round{}, nil, errors.New("The next round has already been paired")
+	}
+	if !extraRound && t.SwissRounds != 0 && len(t.Rounds) >= t.SwissRounds {
+		return Round{}, nil, errSwissFinished
+	}
+
+	var dropped []PlayerID
 	if len(t.Rounds) != 0 {
-		e := t.Rounds[len(t.Rounds)-1].Finish()
+		var e error
+		dropped, e = t.Rounds[len(t.Rounds)-1].Finish()
 		if e != nil {
-			return e
+			return Round{}, nil, e
 		}
+	} else {
+		t.dropAbsentPlayers()
+	}
+	return Round{Tournament: t, Number: len(t.Rounds) + 1}, dropped, nil
+}
+
+// IntentionalDrawPrestige returns how much prestige each player gets for an
+// intentional draw.
+func (t *Tournament) IntentionalDrawPrestige() int {
+	if t.IDPrestige != nil {
+		return *t.IDPrestige
 	}
+	return t.Scoring().IntentionalDraw
+}
+
+// ProgressFunc is told how much of a long job has been done.
+type ProgressFunc func(done, total int)
+
+const defaultPairingTimeLimit = 60 // seconds
+
+// PairingTime returns how long to spend looking for the best pairings for a
+// round before settling for the best found so far.
+func (t *Tournament) PairingTime() time.Duration {
+	if t.PairingTimeLimit > 0 {
+		return time.Duration(t.PairingTimeLimit) * time.Second
+	}
+	return defaultPairingTimeLimit * time.Second
+}
 
-	t.Rounds = append(t.Rounds, Round{Tournament: t, Number: len(t.Rounds) + 1})
-	t.Rounds[len(t.Rounds)-1].MakeMatches()
-	t.Rounds[len(t.Rounds)-1].Start()
+// Draft returns the newest round if it's been paired but not started, or nil.
+func (t *Tournament) Draft() *Round {
+	if len(t.Rounds) == 0 || t.Rounds[len(t.Rounds)-1].Started {
+		return nil
+	}
+	return &(t.Rounds[len(t.Rounds)-1])
+}
+
+// DiscardDraft removes the newest round if it hasn't started.
+func (t *Tournament) DiscardDraft() error {
+	if t.Draft() == nil {
+		return errors.New("There's no unstarted round to discard")
+	}
+	t.Rounds = t.Rounds[:len(t.Rounds)-1]
 	return nil
 }
 
@@ -74,7 +402,28 @@ type Player struct {
 	XSoS            float64
 	CurrentMatch    MatchID
 	FinishedMatches []MatchID
+	Byes            []MatchID // byes and forfeit wins, which rule out another bye
 	Dropped         bool
+	Team            string     // players on the same team avoid playing each other
+	Avoid           []PlayerID // other players this player avoids playing
+	EarnedByes      int        // byes for this many rounds from the start, e.g. from circuit results
+	Tiebreak        float64    // random final tiebreaker, drawn at registration
+	MissedRounds    int        // rounds that had started when the player registered
+	MissedAs        string     // how missed rounds count: MissedNothing, MissedLoss or MissedHalfBye
+	CheckedIn       bool       `json:",omitempty"` // whether the player checked in before round 1
+	NoShowDropped   bool       `json:",omitempty"` // whether the player was dropped automatically for no-shows
+	CheckInDropped  bool       `json:",omitempty"` // whether the player was dropped automatically for not checking in
+}
+
+// Adjustments returns the judge's adjustments to the player's prestige.
+func (p Player) Adjustments() []Adjustment {
+	var adjustments []Adjustment
+	for _, a := range p.Tournament.Adjustments {
+		if a.Player == p.PlayerID {
+			adjustments = append(adjustments, a)
+		}
+	}
+	return adjustments
 }
 
 type PlayerID int
@@ -83,8 +432,9 @@ const NoPlayer PlayerID = -1
 
 // playerSorter joins a Tournament pointer and a slice of PlanetIDs to be sorted.
 type playerSorter struct {
-	t *Tournament
-	p []PlayerID
+	t        *Tournament
+	p        []PlayerID
+	tiebreak bool // whether to break any remaining ties with players' recorded tiebreaks
 }
 
 // Len is part of sort.Interface.
@@ -97,86 +447,79 @@ func (s *playerSorter) Swap(i, j int) {
 	s.p[i], s.p[j] = s.p[j], s.p[i]
 }
 
-// Less is part of sort.Interface. It is implemented by calling the "by" closure in the sorter.
+// Less is part of sort.Interface. Players are ranked on prestige, then on the
+// tournament's tiebreakers.
 func (s *playerSorter) Less(i, j int) bool {
-	pi := s.t.Player(s.p[i])
-	pj := s.t.Player(s.p[j])
-
-	if pi.Prestige != pj.Prestige {
-		return pi.Prestige > pj.Prestige
-	} else if pi.SoS != pj.SoS {
-		return pi.SoS > pj.SoS
-	} else {
-		return pi.XSoS > pj.XSoS
+	st := s.t.currentStandings()
+	a, b := st.Player(s.p[i]), st.Player(s.p[j])
+	if a == nil || b == nil {
+		// players who aren't in the standings yet rank last
+		if a == nil && b == nil {
+			return s.p[i] < s.p[j]
+		}
+		return b == nil
+	}
+	if s.tiebreak {
+		return st.rank(a, b) > 0
 	}
+	return st.compare(a, b) > 0
 }
 
-func (t *Tournament) updateSoS() {
-	if !t.SosUpToDate {
-		// Update prestige averages
-		for i, _ := range t.Players {
-			p := &(t.Players[i])
-			// Note that byes are counted here, because
-			// that's what TOME does
-			if len(p.FinishedMatches) == 0 {
-				p.PrestigeAvg = 0
-			} else {
-				p.PrestigeAvg = float64(p.Prestige) / float64(len(p.FinishedMatches))
-			}
-		}
-
-		// Update SoS
-		for i, _ := range t.Players {
-			p := &(t.Players[i])
-			var SoSSum float64
-			var matchCount int
-			for _, mID := range p.FinishedMatches {
-				m := t.Match(mID)
-				if !m.IsBye() {
-					SoSSum += t.Player(m.GetOpponent(p.PlayerID)).PrestigeAvg
-					matchCount += 1
-				}
-			}
-			if matchCount == 0 {
-				p.SoS = 0
-			} else {
-				p.SoS = SoSSum / float64(matchCount)
-			}
-		}
+// currentStandings returns the standings after every finished round.
+func (t *Tournament) currentStandings() *Standings {
+	if t.standings == nil || !t.SosUpToDate {
+		t.SosUpToDate = false
+		t.updateStats()
+	}
+	return t.standings
+}
 
-		// Update xSoS
-		for i, _ := range t.Players {
-			p := &(t.Players[i])
-			var xSoSSum float64
-			var matchCount int
-			for _, mID := range p.FinishedMatches {
-				m := t.Match(mID)
-				if !m.IsBye() {
-					xSoSSum += t.Player(m.GetOpponent(p.PlayerID)).SoS
-					matchCount += 1
+// updateStats copies each player's stats from the standings after every
+// finished round, unless they're already up to date.
+func (t *Tournament) updateStats() {
+	if t.SosUpToDate {
+		return
+	}
+	standings := t.StandingsAfter(len(t.Rounds))
+	t.standings = standings
+	for i := range t.Players {
+		p := &(t.Players[i])
+		stats := standings.Player(p.PlayerID)
+		p.Prestige = stats.Prestige
+		p.PrestigeAvg = stats.PrestigeAvg
+		p.SoS = stats.SoS
+		p.XSoS = stats.XSoS
+		p.FinishedMatches = stats.FinishedMatches
+		p.Byes = stats.Byes
+		p.CurrentMatch = MatchID{}
+	}
+	for _, r := range t.Rounds {
+		if r.Started && !r.Finished {
+			for _, m := range r.Matches {
+				mID := MatchID{r.Number, m.Number}
+				t.Player(m.Corp).CurrentMatch = mID
+				if m.Runner != NoPlayer {
+					t.Player(m.Runner).CurrentMatch = mID
 				}
 			}
-			if matchCount == 0 {
-				p.XSoS = 0
-			} else {
-				p.XSoS = xSoSSum / float64(matchCount)
-			}
 		}
-		t.SosUpToDate = true
 	}
+	t.SosUpToDate = true
 }
 
 func (t *Tournament) sortPlayers(p []PlayerID) {
-	t.updateSoS()
-	t.ScoreGroups = orderPlayers(t, t.Standings, false)
+	t.updateStats()
+	t.ScoreGroups = orderPlayers(t, t.random(), t.Standings, false)
 }
 
-func shuffleGroups(t *Tournament, players []PlayerID) {
-	orderPlayers(t, players, true)
+func shuffleGroups(t *Tournament, rng *rand.Rand, players []PlayerID) {
+	orderPlayers(t, rng, players, true)
 }
 
-func orderPlayers(t *Tournament, players []PlayerID, shuffleGroups bool) (scoreGroups map[int]int) {
-	sort.Sort(&playerSorter{t, players})
+func orderPlayers(t *Tournament, rng *rand.Rand, players []PlayerID, shuffleGroups bool) (scoreGroups map[int]int) {
+	// Pairing leaves ties to be shuffled, so that the same seed always gives
+	// the same pairings; the standings use the recorded tiebreaks.
+	sort.Sort(&playerSorter{t, players, !shuffleGroups})
 
 	// Record & sort or shuffle the score groups
 	scoreGroups = make(map[int]int)
@@ -184,63 +527,45 @@ func orderPlayers(t *Tournament, players []PlayerID, shuffleGroups bool) (scoreG
 	score := -1
 	groupStart := 0
 	for i, p := range players {
-		if t.Player(p).Prestige != score {
+		// adjustments can make prestige negative, so the first player always starts a group
+		if i == 0 || t.Player(p).Prestige != score {
 			score = t.Player(p).Prestige
 			scoreGroups[score] = group
 			group += 1
-			if i != 0 {
-				if shuffleGroups {
-					shufflePlayers(players[groupStart : i-1])
-				} else {
-					sortScoreGroup(t, players[groupStart:i-1])
-				}
+			if i != 0 && shuffleGroups {
+				shufflePlayers(rng, players[groupStart:i-1])
 			}
 			groupStart = i
 		}
 	}
-	// sort last score group
+	// shuffle last score group
 	if shuffleGroups {
-		shufflePlayers(players[groupStart:])
-	} else {
-		sortScoreGroup(t, players[groupStart:])
+		shufflePlayers(rng, players[groupStart:])
 	}
 
 	return scoreGroups
 }
 
-// sortScoreGroup actually just randomizes ties; SoS and xSoS are handled when the whole list is sorted
-func sortScoreGroup(t *Tournament, g []PlayerID) {
-	tieStart := 0
-	SoS := -1.0
-	xSoS := -1.0
-	for i, p := range g {
-		if t.Player(p).SoS != SoS || t.Player(p).XSoS != xSoS {
-			SoS = t.Player(p).SoS
-			xSoS = t.Player(p).XSoS
-			if i != 0 && i-tieStart > 1 {
-				shufflePlayers(g[tieStart : i-1])
-			}
-			tieStart = i
-		}
-	}
-	// shuffle last tie group
-	shufflePlayers(g[tieStart:])
-}
-
 // basically copied from http://marcelom.github.io/2013/06/07/goshuffle.html
-func shufflePlayers(g []PlayerID) {
+func shufflePlayers(rng *rand.Rand, g []PlayerID) {
 	for i := range g {
-		j := rand.Intn(i + 1)
+		j := rng.Intn(i + 1)
 		g[i], g[j] = g[j], g[i]
 	}
 }
 
 type Game struct {
 	Pairing
-	Concluded   bool
-	CorpWin     bool
-	RunnerWin   bool
-	ModifiedWin bool
+	Concluded       bool
+	CorpWin         bool
+	RunnerWin       bool
+	ModifiedWin     bool
+	IntentionalDraw bool   `json:",omitempty"` // the players agreed to a draw instead of playing
+	IDPrestige      int    `json:",omitempty"` // prestige each player gets for the intentional draw
+	DoubleLoss      bool   `json:",omitempty"` // both players lost, by a judge's ruling
+	GameLoss        bool   `json:",omitempty"` // the loser lost because of a penalty, not by playing
+	Forfeit         bool   `json:",omitempty"` // the loser didn't show up
+	Ruling          string `json:",omitempty"` // the judge's reason for a double loss or game loss
 }
 
 type Round struct {
@@ -249,17 +574,23 @@ type Round struct {
 	Matches    []Match
 	Started    bool
 	Finished   bool
+	Report     *PairingReport `json:",omitempty"`
+	Seed       int64          // random seed the round was paired with
+	Edited     bool           `json:",omitempty"` // whether the pairings were changed by hand
+	TimedOut   bool           `json:",omitempty"` // whether pairing ran out of time, so better pairings may exist
+	// whether an earlier round's results were corrected after this round was
+	// paired, so it may have been paired differently
+	Outdated bool `json:",omitempty"`
 }
 
-type partialRound struct {
-	Tournament       *Tournament
-	Pairings         []Pairing
-	UnmatchedPlayers []PlayerID
-	goodness         roundGoodness
+// TwoGames returns whether the round's matches are two games each.
+func (r Round) TwoGames() bool {
+	return len(r.Matches) != 0 && r.Matches[0].SecondGame != nil
 }
 
 type pairingDetails struct {
 	rematch     int    // number of times these players have played already
+	restricted  bool   // whether these players have asked not to play each other
 	groupDiff   int    // difference between the group numbers of the two players
 	sideDiffs   [2]int // for each player, what their side diff will be after the round
 	streaks     [2]int // for each player, what their streak will be after the round
@@ -267,127 +598,171 @@ type pairingDetails struct {
 	byePrestige int    // how many points the player has, if this is a bye
 }
 
+// Pairing criteria, in the order roundGoodness.BetterThan usually considers them.
+const (
+	rematchTier      = iota
+	restrictionTier  // teammates and players who avoid each other
+	byeTier          // prestige of the player getting the bye
+	sideDiffTier     // side diffs of three or more
+	streakTier       // streaks of three or more
+	groupDiffTier    // score group crossings
+	mildSideDiffTier // side diffs of two
+	mildStreakTier   // streaks of two
+)
+
+var normalTiers = []int{rematchTier, restrictionTier, byeTier, sideDiffTier, streakTier, groupDiffTier, mildSideDiffTier, mildStreakTier}
+
+// FIDE allows side diffs and streaks of three in the last round, so it's more
+// important to pair players within their score groups.
+var lastRoundTiers = []int{rematchTier, restrictionTier, byeTier, groupDiffTier, sideDiffTier, streakTier, mildSideDiffTier, mildStreakTier}
+
+// The criteria that are about which side players play.
+var sideTiers = []int{sideDiffTier, streakTier, mildSideDiffTier, mildStreakTier}
+
+// PairingProfile is a named order of priorities for the pairing criteria.
+// Criteria that aren't listed are ignored.
+type PairingProfile struct {
+	Key            string
+	Name           string
+	Description    string
+	tiers          []int
+	lastRoundTiers []int // tiers for the last swiss round, if it's different
+}
+
+var pairingProfiles = []PairingProfile{
+	{
+		"fide", "FIDE strict",
+		"Side balance comes before score groups, except in the last round, following the FIDE rules.",
+		normalTiers, lastRoundTiers,
+	},
+	{
+		"nrtm", "NRTM-compatible",
+		"Score groups come before side balance in every round, like NRTM.",
+		lastRoundTiers, nil,
+	},
+	{
+		"casual", "Casual: ignore sides",
+		"Only rematches, restrictions, the bye and score groups matter. Players can play the same side as often as it happens.",
+		[]int{rematchTier, restrictionTier, byeTier, groupDiffTier}, nil,
+	},
+}
+
+// Profile returns the tournament's pairing profile. The first one is the
+// default.
+func (t *Tournament) Profile() PairingProfile {
+	for _, p := range pairingProfiles {
+		if p.Key == t.PairingProfile {
+			return p
+		}
+	}
+	return pairingProfiles[0]
+}
+
+func knownProfile(key string) bool {
+	for _, p := range pairingProfiles {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
 type roundGoodness struct {
 	rematches   []int // rematches[i] = number of pairs that are matched for the i-th time
+	restricted  int   // number of pairs that have asked not to play each other
 	groupDiffs  []int // groupDiffs[i] = number of pairs that are matched across i groups
 	sideDiffs   []int // sideDiffs[i] = number of players that will have a side diff of i after the round
 	streaks     []int // streaks[i] = number of players that will have a streak of i after the round
 	hasBye      bool  // whether there's at least one player with a bye
 	byePrestige int   // how many prestige points the player with the bye has
-}
-
-func (src *roundGoodness) copy() roundGoodness {
-	var dst roundGoodness
-	dst.rematches = append(dst.rematches, src.rematches...)
-	dst.groupDiffs = append(dst.groupDiffs, src.groupDiffs...)
-	dst.sideDiffs = append(dst.sideDiffs, src.sideDiffs...)
-	dst.streaks = append(dst.streaks, src.streaks...)
-	dst.hasBye = src.hasBye
-	dst.byePrestige = src.byePrestige
-	return dst
+	tiers       []int // order to consider pairing criteria in; nil means normalTiers
 }
 
 func (g1 *roundGoodness) BetterThan(g2 *roundGoodness) bool {
-	// rematches bad
-	if len(g1.rematches) < len(g2.rematches) {
-		return true
-	} else if len(g1.rematches) > len(g2.rematches) {
-		return false
-	} else if len(g1.rematches) > 1 {
-		for i := len(g1.rematches) - 1; i > 0; i-- {
-			if g1.rematches[i] < g2.rematches[i] {
-				return true
-			} else if g1.rematches[i] > g2.rematches[i] {
-				return false
-			}
-		}
+	tiers := g1.tiers
+	if tiers == nil {
+		tiers = normalTiers
 	}
-
-	// better to assign the bye to a player with a lower score
-	if g1.hasBye && g2.hasBye {
-		if g1.byePrestige < g2.byePrestige {
-			return true
-		} else if g1.byePrestige > g2.byePrestige {
-			return false
+	for _, tier := range tiers {
+		if c := g1.compareTier(g2, tier); c != 0 {
+			return c < 0
 		}
 	}
+	return false
+}
 
-	// side diffs of more than two bad
-	if len(g1.sideDiffs) > 3 || len(g2.sideDiffs) > 3 {
-		if len(g1.sideDiffs) < len(g2.sideDiffs) {
-			return true
-		} else if len(g1.sideDiffs) > len(g2.sideDiffs) {
-			return false
-		} else {
-			for i := len(g1.sideDiffs) - 1; i > 2; i-- {
-				if g1.sideDiffs[i] < g2.sideDiffs[i] {
-					return true
-				} else if g1.sideDiffs[i] > g2.sideDiffs[i] {
-					return false
-				}
-			}
+// compareTier returns -1 if g1 is better than g2 for the given criterion, 1 if
+// it's worse, and 0 if they're equally good.
+func (g1 *roundGoodness) compareTier(g2 *roundGoodness, tier int) int {
+	switch tier {
+	case rematchTier:
+		// rematches bad
+		return compareFromTop(g1.rematches, g2.rematches, 1)
+	case restrictionTier:
+		// pairing players who asked not to play each other bad
+		if g1.restricted < g2.restricted {
+			return -1
+		} else if g1.restricted > g2.restricted {
+			return 1
 		}
-	}
-
-	// streaks of more than two bad
-	if len(g1.streaks) > 3 || len(g2.streaks) > 3 {
-		if len(g1.streaks) < len(g2.streaks) {
-			return true
-		} else if len(g1.streaks) > len(g2.streaks) {
-			return false
-		} else {
-			for i := len(g1.streaks) - 1; i > 2; i-- {
-				if g1.streaks[i] < g2.streaks[i] {
-					return true
-				} else if g1.streaks[i] > g2.streaks[i] {
-					return false
-				}
+	case byeTier:
+		// better to assign the bye to a player with a lower score
+		if g1.hasBye && g2.hasBye {
+			if g1.byePrestige < g2.byePrestige {
+				return -1
+			} else if g1.byePrestige > g2.byePrestige {
+				return 1
 			}
 		}
+	case sideDiffTier:
+		// side diffs of more than two bad
+		return compareFromTop(g1.sideDiffs, g2.sideDiffs, 3)
+	case streakTier:
+		// streaks of more than two bad
+		return compareFromTop(g1.streaks, g2.streaks, 3)
+	case groupDiffTier:
+		// minimize pairings that cross score groups
+		return compareFromTop(g1.groupDiffs, g2.groupDiffs, 0)
+	case mildSideDiffTier:
+		// side diffs of two are mildly undesirable
+		return compareAt(g1.sideDiffs, g2.sideDiffs, 2)
+	case mildStreakTier:
+		// streaks of two are mildly undesirable
+		return compareAt(g1.streaks, g2.streaks, 2)
 	}
+	return 0
+}
 
-	// minimize pairings that cross score groups
-	if len(g1.groupDiffs) < len(g2.groupDiffs) {
-		return true
-	} else if len(g1.groupDiffs) > len(g2.groupDiffs) {
-		return false
-	} else {
-		for i := len(g1.groupDiffs) - 1; i >= 0; i-- {
-			if g1.groupDiffs[i] < g2.groupDiffs[i] {
-				return true
-			} else if g1.groupDiffs[i] > g2.groupDiffs[i] {
-				return false
-			}
-		}
+// compareFromTop compares two histograms from the highest level down to min,
+// where fewer at a higher level is better.
+func compareFromTop(h1, h2 []int, min int) int {
+	top := len(h1)
+	if len(h2) > top {
+		top = len(h2)
 	}
-
-	// side diffs of two are mildly undesirable
-	if len(g1.sideDiffs) > 2 {
-		if len(g2.sideDiffs) <= 2 {
-			return false
-		} else if g1.sideDiffs[2] < g2.sideDiffs[2] {
-			return true
-		} else if g1.sideDiffs[2] > g2.sideDiffs[2] {
-			return false
+	for i := top - 1; i >= min; i-- {
+		if c := compareAt(h1, h2, i); c != 0 {
+			return c
 		}
-	} else if len(g2.sideDiffs) > 2 {
-		return true
 	}
+	return 0
+}
 
-	// streaks of two are mildly undesirable
-	if len(g1.streaks) > 2 {
-		if len(g2.streaks) <= 2 {
-			return false
-		} else if g1.streaks[2] < g2.streaks[2] {
-			return true
-		} else if g1.streaks[2] > g2.streaks[2] {
-			return false
-		}
-	} else if len(g2.streaks) > 2 {
-		return true
+// compareAt compares two histograms at a single level, where fewer is better.
+func compareAt(h1, h2 []int, i int) int {
+	var c1, c2 int
+	if i < len(h1) {
+		c1 = h1[i]
 	}
-
-	return false
+	if i < len(h2) {
+		c2 = h2[i]
+	}
+	if c1 < c2 {
+		return -1
+	} else if c1 > c2 {
+		return 1
+	}
+	return 0
 }
 
 func (g *roundGoodness) addPairing(p pairingDetails) {
@@ -397,6 +772,10 @@ func (g *roundGoodness) addPairing(p pairingDetails) {
 	}
 	g.rematches[p.rematch] += 1
 
+	if p.restricted {
+		g.restricted += 1
+	}
+
 	if p.isBye {
 		if !g.hasBye || p.byePrestige > g.byePrestige {
 			// if multiple byes, byePrestige is the highest of the prestiges of players with byes
@@ -440,11 +819,18 @@ func (t *Tournament) pairingEffects(corpID, runnerID PlayerID) pairingDetails {
 
 	corp := t.Player(corpID)
 	runner := t.Player(runnerID)
-	for _, mID := range corp.FinishedMatches {
-		m := t.Match(mID)
-		if m.Corp == runnerID || m.Runner == runnerID {
-			d.rematch += 1
+	if runnerID == NoPlayer {
+		// FIDE: no pairing-allocated bye for a player who's already had a
+		// bye or a forfeit win
+		d.rematch = len(corp.Byes)
+	} else {
+		for _, mID := range corp.FinishedMatches {
+			m := t.Match(mID)
+			if m.Corp == runnerID || m.Runner == runnerID {
+				d.rematch += 1
+			}
 		}
+		d.restricted = t.restricted(corpID, runnerID)
 	}
 
 	if runnerID == NoPlayer {
@@ -467,12 +853,15 @@ func (t *Tournament) pairingEffects(corpID, runnerID PlayerID) pairingDetails {
 	return d
 }
 
+// The side effects functions skip byes, intentional draws and forfeits, since
+// neither player actually played a side.
+
 func (t *Tournament) playerCorpEffects(p PlayerID) (sideDiff, streak int) {
 	sideDiff = 1
 	streak = 1
 	for _, mID := range t.Player(p).FinishedMatches {
 		m := t.Match(mID)
-		if !m.IsBye() {
+		if !m.IsBye() && !m.IntentionalDraw && !m.Forfeit {
 			if m.Corp == p {
 				sideDiff += 1
 				streak += 1
@@ -494,7 +883,7 @@ func (t *Tournament) playerRunnerEffects(p PlayerID) (sideDiff, streak int) {
 	streak = 1
 	for _, mID := range t.Player(p).FinishedMatches {
 		m := t.Match(mID)
-		if !m.IsBye() {
+		if !m.IsBye() && !m.IntentionalDraw && !m.Forfeit {
 			if m.Corp == p {
 				sideDiff += 1
 				streak = 1
@@ -517,7 +906,7 @@ func (t *Tournament) playerByeEffects(p PlayerID) (sideDiff, streak int) {
 	runnerStreak := true
 	for _, mID := range t.Player(p).FinishedMatches {
 		m := t.Match(mID)
-		if !m.IsBye() {
+		if !m.IsBye() && !m.IntentionalDraw && !m.Forfeit {
 			if m.Corp == p {
 				sideDiff += 1
 				if runnerStreak {
@@ -544,47 +933,229 @@ func (t *Tournament) playerByeEffects(p PlayerID) (sideDiff, streak int) {
 	return
 }
 
-func (p *partialRound) appendMatch(corp, runner PlayerID) partialRound {
-	var newPartial partialRound
-	newPartial.Pairings = append([]Pairing(nil), p.Pairings...)
-	newPartial.Pairings = append(newPartial.Pairings, Pairing{Corp: corp, Runner: runner})
+// penaltyDigit is one place value in the cost of a pairing. Within a tier,
+// higher levels are worse, so they're more significant digits.
+type penaltyDigit struct {
+	tier  int
+	level int
+}
+
+// penalties returns how much this pairing adds to each digit of the cost of
+// the round.
+func (d pairingDetails) penalties() map[penaltyDigit]int {
+	pen := make(map[penaltyDigit]int)
+	if d.rematch > 0 {
+		pen[penaltyDigit{rematchTier, d.rematch}] += 1
+	}
+	if d.restricted {
+		pen[penaltyDigit{restrictionTier, 1}] += 1
+	}
+	if d.isBye && d.byePrestige != 0 {
+		pen[penaltyDigit{byeTier, 0}] += d.byePrestige
+	}
+	if d.groupDiff > 0 {
+		pen[penaltyDigit{groupDiffTier, d.groupDiff}] += 1
+	}
+	for i := 0; i < 2; i++ {
+		if d.sideDiffs[i] > 2 {
+			pen[penaltyDigit{sideDiffTier, d.sideDiffs[i]}] += 1
+		} else if d.sideDiffs[i] == 2 {
+			pen[penaltyDigit{mildSideDiffTier, 2}] += 1
+		}
+		if d.streaks[i] > 2 {
+			pen[penaltyDigit{streakTier, d.streaks[i]}] += 1
+		} else if d.streaks[i] == 2 {
+			pen[penaltyDigit{mildStreakTier, 2}] += 1
+		}
+	}
+	return pen
+}
+
+// digitSorter orders penalty digits from most to least significant, given
+// the rank of each tier.
+type digitSorter struct {
+	digits []penaltyDigit
+	rank   map[int]int
+}
 
-	newPartial.Tournament = p.Tournament
+func (s digitSorter) Len() int      { return len(s.digits) }
+func (s digitSorter) Swap(i, j int) { s.digits[i], s.digits[j] = s.digits[j], s.digits[i] }
+func (s digitSorter) Less(i, j int) bool {
+	di, dj := s.digits[i], s.digits[j]
+	if di.tier != dj.tier {
+		return s.rank[di.tier] < s.rank[dj.tier]
+	}
+	return di.level > dj.level
+}
 
-	newPartial.goodness = p.goodness.copy()
-	newPartial.goodness.addPairing(newPartial.Tournament.pairingEffects(corp, runner))
+// pairingCosts turns the effects of each possible pairing into a single
+// number, chosen so that comparing the total cost of two rounds gives the same
+// answer as roundGoodness.BetterThan with the given tiers. Each penalty digit
+// gets its own place value, with a base large enough that no digit can
+// overflow into the next.
+func pairingCosts(details []pairingDetails, playerCount int, tiers []int) (costs []*big.Int, maxCost *big.Int) {
+	rank := make(map[int]int)
+	for i, tier := range tiers {
+		rank[tier] = i
+	}
 
-	if len(p.UnmatchedPlayers) > 2 {
-		newPartial.UnmatchedPlayers = make([]PlayerID, 0, len(p.UnmatchedPlayers)-2)
+	// Bye penalties count up from the lowest score, which can be below zero
+	// after prestige adjustments.
+	minBye := 0
+	for _, d := range details {
+		if d.isBye && d.byePrestige < minBye {
+			minBye = d.byePrestige
+		}
+	}
 
-		for _, unmatchedPlayer := range p.UnmatchedPlayers {
-			if unmatchedPlayer != corp && unmatchedPlayer != runner {
-				newPartial.UnmatchedPlayers = append(newPartial.UnmatchedPlayers, unmatchedPlayer)
+	penalties := make([]map[penaltyDigit]int, len(details))
+	digitSet := make(map[penaltyDigit]bool)
+	base := playerCount + 1
+	for i, d := range details {
+		penalties[i] = d.penalties()
+		if d.isBye && minBye < 0 {
+			penalties[i][penaltyDigit{byeTier, 0}] = d.byePrestige - minBye
+		}
+		for digit := range penalties[i] {
+			if _, ok := rank[digit.tier]; ok {
+				digitSet[digit] = true
+			} else {
+				delete(penalties[i], digit)
 			}
 		}
-	} else {
-		newPartial.UnmatchedPlayers = make([]PlayerID, 0)
+		if d.isBye && d.byePrestige-minBye >= base {
+			base = d.byePrestige - minBye + 1
+		}
 	}
 
-	return newPartial
+	var digits []penaltyDigit
+	for digit := range digitSet {
+		digits = append(digits, digit)
+	}
+	sort.Sort(digitSorter{digits, rank})
+
+	bigBase := big.NewInt(int64(base))
+	placeValues := make(map[penaltyDigit]*big.Int)
+	placeValue := big.NewInt(1)
+	for i := len(digits) - 1; i >= 0; i-- {
+		placeValues[digits[i]] = placeValue
+		placeValue = new(big.Int).Mul(placeValue, bigBase)
+	}
+
+	costs = make([]*big.Int, len(details))
+	for i, pen := range penalties {
+		costs[i] = big.NewInt(0)
+		for digit, count := range pen {
+			c := new(big.Int).Mul(placeValues[digit], big.NewInt(int64(count)))
+			costs[i].Add(costs[i], c)
+		}
+	}
+	return costs, placeValue
 }
 
-func (p *partialRound) NextMatches(partials chan partialRound, stop chan int) {
-	if len(p.UnmatchedPlayers) == 1 {
-		partials <- p.appendMatch(p.UnmatchedPlayers[0], NoPlayer)
-	} else if len(p.UnmatchedPlayers) == 2 {
-		partials <- p.appendMatch(p.UnmatchedPlayers[0], p.UnmatchedPlayers[1])
-		partials <- p.appendMatch(p.UnmatchedPlayers[1], p.UnmatchedPlayers[0])
-	} else if len(p.UnmatchedPlayers) > 2 {
-		for _, playerB := range p.UnmatchedPlayers[1:] {
-			partials <- p.appendMatch(p.UnmatchedPlayers[0], playerB)
-			partials <- p.appendMatch(playerB, p.UnmatchedPlayers[0])
+// bestPairings pairs the given players by finding a maximum weight matching on
+// the graph of all possible pairings, weighted by pairingCosts. Players should
+// be in standings order; ties between equally good pairings are broken by
+// that order and by coin flips for sides.
+//
+// If ctx is done before the best pairings are found, it returns the best it
+// could do in the time and complete is false.
+func (t *Tournament) bestPairings(ctx context.Context, rng *rand.Rand, players []PlayerID, tiers []int, progress ProgressFunc) (pairings []Pairing, complete bool) {
+	if len(players)%2 == 1 {
+		players = append(players, NoPlayer)
+	}
+
+	// Both side assignments are considered for every pair of players; byes
+	// only go one way.
+	var candidates []Pairing
+	var details []pairingDetails
+	for i, a := range players {
+		for _, b := range players[i+1:] {
+			if b == NoPlayer {
+				candidates = append(candidates, Pairing{Corp: a, Runner: NoPlayer})
+				details = append(details, t.pairingEffects(a, NoPlayer))
+			} else {
+				candidates = append(candidates, Pairing{Corp: a, Runner: b}, Pairing{Corp: b, Runner: a})
+				details = append(details, t.pairingEffects(a, b), t.pairingEffects(b, a))
+			}
+		}
+	}
+	costs, maxCost := pairingCosts(details, len(players), tiers)
+
+	var edges []weightedEdge
+	var edgePairings []Pairing
+	c := 0
+	for i := range players {
+		for j := i + 1; j < len(players); j++ {
+			best := c
+			if players[j] != NoPlayer {
+				cmp := costs[c].Cmp(costs[c+1])
+				if cmp > 0 || (cmp == 0 && rng.Intn(2) == 1) {
+					best = c + 1
+				}
+				c += 2
+			} else {
+				c += 1
+			}
+			edges = append(edges, weightedEdge{i, j, new(big.Int).Sub(maxCost, costs[best])})
+			edgePairings = append(edgePairings, candidates[best])
+		}
+	}
+
+	mate, complete := maxWeightMatching(ctx, edges, progress)
+
+	pairings = make([]Pairing, 0, len(players)/2)
+	var bye []Pairing
+	for k, e := range edges {
+		if mate[e.i] != e.j {
+			continue
 		}
-		if len(p.UnmatchedPlayers)%2 == 1 {
-			partials <- p.appendMatch(p.UnmatchedPlayers[0], NoPlayer)
+		if edgePairings[k].Runner == NoPlayer {
+			bye = append(bye, edgePairings[k])
+		} else {
+			pairings = append(pairings, edgePairings[k])
 		}
 	}
-	stop <- 1
+	return append(pairings, bye...), complete
+}
+
+// pairingTiers returns the order to consider pairing criteria in for the
+// given round.
+func (t *Tournament) pairingTiers(round int) []int {
+	profile := t.Profile()
+	tiers := profile.tiers
+	if t.SwissRounds != 0 && round == t.SwissRounds && profile.lastRoundTiers != nil {
+		tiers = profile.lastRoundTiers
+	}
+	if t.TwoGameRounds {
+		// everyone plays both sides, so sides don't matter
+		for _, tier := range sideTiers {
+			tiers = withoutTier(tiers, tier)
+		}
+	}
+	if t.RestrictionRounds != 0 && round > t.RestrictionRounds {
+		tiers = withoutTier(tiers, restrictionTier)
+	}
+	return tiers
+}
+
+func hasTier(tiers []int, tier int) bool {
+	for _, t := range tiers {
+		if t == tier {
+			return true
+		}
+	}
+	return false
+}
+
+func withoutTier(tiers []int, tier int) []int {
+	result := make([]int, 0, len(tiers))
+	for _, t := range tiers {
+		if t != tier {
+			result = append(result, t)
+		}
+	}
+	return result
 }
 
 func (t Tournament) activePlayers() []PlayerID {
@@ -597,72 +1168,160 @@ func (t Tournament) activePlayers() []PlayerID {
 	return players
 }
 
+// earnedBye returns whether a player gets a bye in the given round without
+// being paired.
+func (t *Tournament) earnedBye(p PlayerID, round int) bool {
+	return t.Player(p).EarnedByes >= round
+}
+
+// MakeMatches pairs the round with a new random seed.
 func (r *Round) MakeMatches() {
+	r.MakeMatchesContext(context.Background(), nil)
+}
+
+// MakeMatchesContext is MakeMatches with a context that limits how long
+// pairing can take, and an optional function to report progress to.
+func (r *Round) MakeMatchesContext(ctx context.Context, progress ProgressFunc) {
+	r.pair(ctx, r.Tournament.random().Int63(), progress)
+}
+
+// pair pairs the round using the given random seed. Given the same seed and
+// the same tournament state, it always comes up with the same pairings, as
+// long as it doesn't run out of time.
+func (r *Round) pair(ctx context.Context, seed int64, progress ProgressFunc) {
+	r.Seed = seed
+	r.Edited = false
+	r.Outdated = false
+	rng := rand.New(rand.NewSource(seed))
+
+	// players with earned byes get them before anyone else is paired
+	var players []PlayerID
+	var earnedByes []Pairing
+	for _, p := range r.Tournament.activePlayers() {
+		if r.Tournament.earnedBye(p, r.Number) {
+			earnedByes = append(earnedByes, Pairing{Corp: p, Runner: NoPlayer})
+		} else {
+			players = append(players, p)
+		}
+	}
+
 	var bestPairings []Pairing
+	var complete bool
 	if r.Number == 1 {
-		players := r.Tournament.activePlayers()
-		shufflePlayers(players)
-		if len(players)%2 == 1 {
-			players = append(players, NoPlayer)
+		// Nobody has played yet, so the only thing that matters is keeping
+		// restricted players apart; otherwise the pairings are random.
+		shufflePlayers(rng, players)
+		var tiers []int
+		if hasTier(r.Tournament.pairingTiers(r.Number), restrictionTier) {
+			tiers = []int{restrictionTier}
 		}
+		bestPairings, complete = r.Tournament.bestPairings(ctx, rng, players, tiers, progress)
+	} else {
+		shuffleGroups(r.Tournament, rng, players)
+		bestPairings, complete = r.Tournament.bestPairings(ctx, rng, players, r.Tournament.pairingTiers(r.Number), progress)
+	}
+	r.TimedOut = !complete
+	r.setPairings(append(bestPairings, earnedByes...))
+}
 
-		bestPairings = make([]Pairing, 0, len(players)/2)
-		for i := 0; i < len(players)/2; i++ {
-			bestPairings = append(bestPairings, Pairing{Corp: players[2*i], Runner: players[2*i+1]})
+// setPairings makes the round's matches from the given pairings and works out
+// the pairing report for them.
+func (r *Round) setPairings(pairings []Pairing) {
+	r.Report = r.Tournament.pairingReport(pairings, r.Tournament.pairingTiers(r.Number), r.Number)
+	r.Matches = make([]Match, 0, len(pairings))
+	for i, pairing := range pairings {
+		m := Match{Game: Game{Pairing: pairing}, Number: i + 1}
+		if r.Tournament.TwoGameRounds {
+			m.SecondGame = &Game{Pairing: Pairing{Corp: pairing.Runner, Runner: pairing.Corp}}
 		}
-	} else {
-		partials := make(chan partialRound)
-		stops := make(chan int)
-		result := make(chan []Pairing)
-		go func(partials chan partialRound, stops chan int) {
-			activeRoundCreators := 1 // MakeMatches() is one until it's sent off the base partial
-			var bestSoFar partialRound
-			for {
-				select {
-				case p := <-partials:
-					// further matching can only make derivatives of p worse,
-					// so if it's not currently better than bestSoFar then
-					// it never will be and we can just toss it out now
-					if len(bestSoFar.Pairings) == 0 || p.goodness.BetterThan(&(bestSoFar.goodness)) {
-						if len(p.UnmatchedPlayers) == 0 {
-							bestSoFar = p
-						} else {
-							go p.NextMatches(partials, stops)
-							activeRoundCreators += 1
-						}
-					} else {
-					}
-				case <-stops:
-					activeRoundCreators -= 1
-				default:
-					if activeRoundCreators == 0 {
-						result <- bestSoFar.Pairings
-						return
-					}
-				}
+		if pairing.Runner == NoPlayer {
+			// bye
+			m.EarnedBye = r.Tournament.earnedBye(pairing.Corp, r.Number)
+			m.Game.RecordResult(pairing.Corp, false)
+			if m.SecondGame != nil {
+				m.SecondGame.RecordResult(pairing.Corp, false)
 			}
-		}(partials, stops)
+		}
+		r.Matches = append(r.Matches, m)
+	}
+}
 
-		var basePartialMatch partialRound
-		basePartialMatch.UnmatchedPlayers = r.Tournament.activePlayers()
-		shuffleGroups(r.Tournament, basePartialMatch.UnmatchedPlayers)
+// Pairings returns the pairings of the round's matches.
+func (r *Round) Pairings() []Pairing {
+	pairings := make([]Pairing, 0, len(r.Matches))
+	for _, m := range r.Matches {
+		pairings = append(pairings, m.Game.Pairing)
+	}
+	return pairings
+}
 
-		basePartialMatch.Tournament = r.Tournament
-		partials <- basePartialMatch
-		stops <- 1
+var errRoundStarted = errors.New("Pairings can't be changed once the round has started")
 
-		bestPairings = <-result
+// SwapPlayers swaps two players between their matches in a round that
+// hasn't started. Either of them can be the player with the bye.
+func (r *Round) SwapPlayers(a, b PlayerID) error {
+	if r.Started {
+		return errRoundStarted
 	}
-	r.Matches = make([]Match, 0, len(r.Tournament.Players)/2)
-	for i, pairing := range bestPairings {
-		r.Matches = append(r.Matches, Match{Game: Game{Pairing: pairing}, Number: i + 1})
-		if pairing.Runner == NoPlayer {
-			// bye
-			r.Matches[len(r.Matches)-1].Game.RecordResult(pairing.Corp, false)
+	pairings := r.Pairings()
+	var found int
+	for i := range pairings {
+		p := &(pairings[i])
+		if p.Corp == a {
+			p.Corp = b
+			found += 1
+		} else if p.Corp == b {
+			p.Corp = a
+			found += 1
+		}
+		if p.Runner == a {
+			p.Runner = b
+			found += 1
+		} else if p.Runner == b {
+			p.Runner = a
+			found += 1
 		}
 	}
+	if found != 2 || a == b {
+		return errors.New("Both players must be in the round")
+	}
+	r.setPairings(pairings)
+	r.Edited = true
+	return nil
 }
 
+// FlipSides swaps the sides of the players in a match in a round that
+// hasn't started.
+func (r *Round) FlipSides(number int) error {
+	if r.Started {
+		return errRoundStarted
+	}
+	if number < 1 || number > len(r.Matches) {
+		return errors.New("No such match")
+	}
+	if r.Matches[number-1].IsBye() {
+		return errors.New("A bye has no sides to swap")
+	}
+	pairings := r.Pairings()
+	p := &(pairings[number-1])
+	p.Corp, p.Runner = p.Runner, p.Corp
+	r.setPairings(pairings)
+	r.Edited = true
+	return nil
+}
+
+// Reroll throws away the pairings for a round that hasn't started and pairs
+// it again.
+func (r *Round) Reroll(ctx context.Context, progress ProgressFunc) error {
+	if r.Started {
+		return errRoundStarted
+	}
+	r.MakeMatchesContext(ctx, progress)
+	return nil
+}
+
+// Start starts the round. Players who registered after it was paired aren't
+// in it, so it counts as one of the rounds they missed.
 func (r *Round) Start() {
 	if !r.Started {
 		r.Started = true
@@ -673,37 +1332,113 @@ func (r *Round) Start() {
 				r.Tournament.Player(m.Runner).CurrentMatch = mID
 			}
 		}
+		for i := range r.Tournament.Players {
+			p := &(r.Tournament.Players[i])
+			if p.MissedRounds == r.Number-1 && !r.hasPlayer(p.PlayerID) {
+				p.MissedRounds = r.Number
+			}
+		}
 	}
 }
 
-func (r *Round) Finish() error {
-	if r.Started && !r.Finished {
-		r.Finished = true
-		for _, m := range r.Matches {
-			if !m.Concluded {
-				return errors.New("Some matches not recorded")
-			}
+func (r *Round) hasPlayer(p PlayerID) bool {
+	for _, m := range r.Matches {
+		if m.Corp == p || m.Runner == p {
+			return true
+		}
+	}
+	return false
+}
+
+// MissingPlayers returns the players who can play but aren't in the round,
+// because they registered or were re-added after it was paired.
+func (r *Round) MissingPlayers() []PlayerID {
+	var missing []PlayerID
+	for _, p := range r.Tournament.activePlayers() {
+		if !r.hasPlayer(p) {
+			missing = append(missing, p)
 		}
+	}
+	return missing
+}
+
+// Finish finishes the round once every result is in, and updates the
+// standings. It returns the players it dropped for reaching the no-show
+// limit.
+func (r *Round) Finish() ([]PlayerID, error) {
+	if !r.Started {
+		return nil, errors.New("The round hasn't started yet")
+	}
+	if !r.Finished {
 		for _, m := range r.Matches {
-			mID := MatchID{r.Number, m.Number}
-			corp := r.Tournament.Player(m.Corp)
-			runner := r.Tournament.Player(m.Runner)
-			corp.Prestige += m.GetPrestige(m.Corp)
-			corp.FinishedMatches = append(corp.FinishedMatches, mID)
-			corp.CurrentMatch = MatchID{}
-			if runner != nil {
-				runner.Prestige += m.GetPrestige(m.Runner)
-				runner.FinishedMatches = append(runner.FinishedMatches, mID)
-				runner.CurrentMatch = MatchID{}
+			if !m.IsDone() {
+				return nil, errors.New("Some matches not recorded")
 			}
 		}
+		r.Finished = true
+		r.Tournament.RecomputeStandings()
+		return r.Tournament.dropNoShows(r.Number), nil
+	}
+
+	return nil, nil
+}
+
+// dropNoShows drops the players who didn't show up in the given round, if
+// they've now reached the tournament's no-show limit, and returns them.
+func (t *Tournament) dropNoShows(round int) []PlayerID {
+	limit := t.NoShowDropLimit
+	if limit <= 0 {
+		return nil
+	}
+	var dropped []PlayerID
+	for _, m := range t.Rounds[round-1].Matches {
+		if !m.Forfeit {
+			continue
+		}
+		absent := t.Player(m.GetOpponent(m.GetWinner()))
+		if !absent.Dropped && t.NoShows(absent.PlayerID) >= limit {
+			absent.Dropped = true
+			absent.NoShowDropped = true
+			dropped = append(dropped, absent.PlayerID)
+		}
+	}
+	return dropped
+}
 
-		r.Tournament.SosUpToDate = false
-		r.Tournament.updateSoS()
-		r.Tournament.sortPlayers(r.Tournament.Standings)
+// reAddNoShows re-adds the players who were dropped for no-shows but no
+// longer have enough of them, and returns them.
+func (t *Tournament) reAddNoShows() []PlayerID {
+	if t.NoShowDropLimit <= 0 {
+		return nil
+	}
+	var reAdded []PlayerID
+	for i := range t.Players {
+		p := &(t.Players[i])
+		if p.NoShowDropped && t.NoShows(p.PlayerID) < t.NoShowDropLimit {
+			t.ReAddPlayer(p.PlayerID)
+			reAdded = append(reAdded, p.PlayerID)
+		}
 	}
+	return reAdded
+}
 
-	return nil
+// RecomputeStandings works out every player's prestige, match history, SoS
+// and standing again from the recorded results and adjustments.
+func (t *Tournament) RecomputeStandings() {
+	t.SosUpToDate = false
+	t.sortPlayers(t.Standings)
+}
+
+// CorrectResults recomputes the standings after results in a finished round
+// have been changed, and marks every later round as outdated. Since the
+// corrected results might have changed who didn't show up, it also checks
+// the no-show limit again, and returns the players it dropped and re-added.
+func (t *Tournament) CorrectResults(round int) (dropped, reAdded []PlayerID) {
+	t.RecomputeStandings()
+	for i := round; i < len(t.Rounds); i++ {
+		t.Rounds[i].Outdated = true
+	}
+	return t.dropNoShows(round), t.reAddNoShows()
 }
 
 func (g *Game) RecordResult(winner PlayerID, modifiedWin bool) {
@@ -711,37 +1446,96 @@ func (g *Game) RecordResult(winner PlayerID, modifiedWin bool) {
 	g.CorpWin = (winner == g.Corp)
 	g.RunnerWin = (winner == g.Runner)
 	g.ModifiedWin = modifiedWin
+	g.IntentionalDraw = false
+	g.IDPrestige = 0
+	g.DoubleLoss = false
+	g.GameLoss = false
+	g.Forfeit = false
+	g.Ruling = ""
+}
+
+// RecordForfeit records that the given player didn't show up, so their
+// opponent wins.
+func (g *Game) RecordForfeit(absent PlayerID) {
+	winner := g.Corp
+	if absent == g.Corp {
+		winner = g.Runner
+	}
+	g.RecordResult(winner, false)
+	g.Forfeit = true
+}
+
+// RecordDoubleLoss records that a judge ruled that both players lost.
+func (g *Game) RecordDoubleLoss(ruling string) {
+	g.RecordResult(NoPlayer, false)
+	g.DoubleLoss = true
+	g.Ruling = ruling
+}
+
+// RecordGameLoss records that a judge gave the given player a game loss as a
+// penalty. Their opponent gets a normal win.
+func (g *Game) RecordGameLoss(loser PlayerID, ruling string) {
+	winner := g.Corp
+	if loser == g.Corp {
+		winner = g.Runner
+	}
+	g.RecordResult(winner, false)
+	g.GameLoss = true
+	g.Ruling = ruling
 }
 
-func (g Game) CorpPrestige() int {
+// RecordIntentionalDraw records that the players agreed to a draw, each
+// scoring the given prestige.
+func (g *Game) RecordIntentionalDraw(prestige int) {
+	g.RecordResult(NoPlayer, false)
+	g.IntentionalDraw = true
+	g.IDPrestige = prestige
+}
+
+func (g Game) CorpPrestige(s Scoring) int {
 	if !g.Concluded {
 		return 0
 	} else if g.CorpWin {
 		if g.ModifiedWin {
-			return 2
+			return s.ModifiedWin
 		} else {
-			return 3
+			return s.Win
 		}
-	} else if g.RunnerWin {
-		return 0
+	} else if g.RunnerWin || g.DoubleLoss {
+		return s.Loss
+	} else if g.IntentionalDraw {
+		return g.IDPrestige
 	} else {
-		return 1
+		return s.Tie
 	}
 }
 
-func (g Game) RunnerPrestige() int {
+func (g Game) RunnerPrestige(s Scoring) int {
 	if !g.Concluded {
 		return 0
 	} else if g.RunnerWin {
 		if g.ModifiedWin {
-			return 2
+			return s.ModifiedWin
 		} else {
-			return 3
+			return s.Win
 		}
-	} else if g.CorpWin {
-		return 0
+	} else if g.CorpWin || g.DoubleLoss {
+		return s.Loss
+	} else if g.IntentionalDraw {
+		return g.IDPrestige
 	} else {
-		return 1
+		return s.Tie
+	}
+}
+
+// Prestige returns the prestige the given player scored in this game.
+func (g Game) Prestige(p PlayerID, s Scoring) int {
+	if p == g.Corp {
+		return g.CorpPrestige(s)
+	} else if p == g.Runner {
+		return g.RunnerPrestige(s)
+	} else {
+		return 0
 	}
 }
 
@@ -752,7 +1546,9 @@ type Pairing struct {
 
 type Match struct {
 	Game
-	Number int
+	SecondGame *Game `json:",omitempty"` // in two-game rounds, the game with sides swapped
+	Number     int
+	EarnedBye  bool `json:",omitempty"` // whether this is a bye the player earned before the tournament
 }
 
 type MatchID struct {
@@ -778,18 +1574,21 @@ func (m Match) IsBye() bool {
 	return (m.Corp == NoPlayer || m.Runner == NoPlayer)
 }
 func (m Match) IsDone() bool {
-	return m.Game.Concluded
+	return m.Game.Concluded && (m.SecondGame == nil || m.SecondGame.Concluded)
 }
-func (m Match) GetPrestige(p PlayerID) int {
+func (m Match) GetPrestige(p PlayerID, s Scoring) int {
 	if p != m.Corp && p != m.Runner {
 		return 0
 	} else if m.Runner == NoPlayer || m.Corp == NoPlayer {
 		//Bye
-		return 3
-	} else if m.Corp == p {
-		return m.Game.CorpPrestige()
+		if m.SecondGame != nil {
+			return 2 * s.Bye
+		}
+		return s.Bye
+	} else if m.SecondGame != nil {
+		return m.Game.Prestige(p, s) + m.SecondGame.Prestige(p, s)
 	} else {
-		return m.Game.RunnerPrestige()
+		return m.Game.Prestige(p, s)
 	}
 }
 func (m Match) GetOpponent(p PlayerID) PlayerID {
@@ -803,11 +1602,24 @@ func (m Match) GetOpponent(p PlayerID) PlayerID {
 }
 
 func (m Match) GetWinner() PlayerID {
+	if m.SecondGame != nil && !m.IsBye() {
+		// whoever scored more over both games; the default scoring is
+		// used so that the winner doesn't depend on the scheme
+		corp, runner := m.GetPrestige(m.Corp, defaultScoring), m.GetPrestige(m.Runner, defaultScoring)
+		if corp > runner {
+			return m.Corp
+		} else if runner > corp {
+			return m.Runner
+		} else {
+			return NoPlayer
+		}
+	}
 	if m.Game.RunnerWin {
 		return m.Runner
 	} else if m.Game.CorpWin {
 		return m.Corp
 	} else {
+		// tie, intentional draw or double loss
 		return NoPlayer
 	}
 }
@@ -888,16 +1700,27 @@ func loadSave(t *Tournament, file string, number int) error {
 			return e
 		}
 		if h.Reason != "" && h.Number == number {
-			e = dec.Decode(t)
+			// decode into a fresh Tournament so nothing is left over from
+			// the current one if the save doesn't have it
+			var loaded Tournament
+			e = dec.Decode(&loaded)
 			if e != nil {
 				return e
 			} else {
+				*t = loaded
 				for i, _ := range t.Players {
 					t.Players[i].Tournament = t
+					if t.Players[i].Tiebreak == 0 {
+						// saved before tiebreaks were recorded
+						t.Players[i].Tiebreak = rand.New(rand.NewSource(int64(t.Players[i].PlayerID))).Float64()
+					}
 				}
 				for i, _ := range t.Rounds {
 					t.Rounds[i].Tournament = t
 				}
+				if t.Cut != nil {
+					t.Cut.Tournament = t
+				}
 				return nil
 			}
 		}
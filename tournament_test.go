@@ -1,36 +1,42 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+)
 
 // Game tests
 var t Tournament
-var c = Player{Name: "Alice", Corp: "EtF", Runner: "Noise", Tournament: &t}
-var r = Player{Name: "Bob", Corp: "PE", Runner: "Mac", Tournament: &t}
+var c = Player{PlayerID: 1, Name: "Alice", Corp: "EtF", Runner: "Noise", Tournament: &t}
+var r = Player{PlayerID: 2, Name: "Bob", Corp: "PE", Runner: "Mac", Tournament: &t}
 var gameTests = []struct {
-	corp           *Player
-	runner         *Player
-	winner         *Player
+	corp           PlayerID
+	runner         PlayerID
+	winner         PlayerID
 	timed          bool
 	corpPrestige   int
 	runnerPrestige int
 	desc           string
 }{
-	{&c, &r, &c, false, 3, 0, "Corp win"},
-	{&c, &r, &c, true, 2, 0, "Corp timed win"},
-	{&c, &r, nil, false, 1, 1, "Tie"},
-	{&c, &r, &r, true, 0, 2, "Runner timed win"},
-	{&c, &r, &r, false, 0, 3, "Runner win"},
+	{c.PlayerID, r.PlayerID, c.PlayerID, false, 3, 0, "Corp win"},
+	{c.PlayerID, r.PlayerID, c.PlayerID, true, 2, 0, "Corp timed win"},
+	{c.PlayerID, r.PlayerID, NoPlayer, false, 1, 1, "Tie"},
+	{c.PlayerID, r.PlayerID, r.PlayerID, true, 0, 2, "Runner timed win"},
+	{c.PlayerID, r.PlayerID, r.PlayerID, false, 0, 3, "Runner win"},
 }
 
 func TestMatches(t *testing.T) {
 	for _, data := range gameTests {
-		m := Match{Game{Pairing: Pairing{Corp: data.corp, Runner: data.runner}}}
+		m := Match{Game: Game{Pairing: Pairing{Corp: data.corp, Runner: data.runner}}}
 		m.Game.RecordResult(data.winner, data.timed)
 		if !m.IsDone() {
 			t.Error("Match with result recorded returned false for IsDone()")
 		}
-		cp := m.Game.CorpPrestige()
-		rp := m.Game.RunnerPrestige()
+		cp := m.Game.CorpPrestige(defaultScoring)
+		rp := m.Game.RunnerPrestige(defaultScoring)
 		if cp != data.corpPrestige || rp != data.runnerPrestige {
 			t.Error("For", data.desc,
 				"expected corp prestige", data.corpPrestige,
@@ -39,7 +45,7 @@ func TestMatches(t *testing.T) {
 				"and runner prestige", rp,
 			)
 		}
-		mcp := m.GetPrestige(data.corp)
+		mcp := m.GetPrestige(data.corp, defaultScoring)
 		if mcp != cp {
 			t.Error("For", data.desc,
 				"game prestige", cp,
@@ -47,7 +53,7 @@ func TestMatches(t *testing.T) {
 				"for corp player did not match",
 			)
 		}
-		mrp := m.GetPrestige(data.runner)
+		mrp := m.GetPrestige(data.runner, defaultScoring)
 		if mrp != rp {
 			t.Error("For", data.desc,
 				"game prestige", rp,
@@ -55,13 +61,13 @@ func TestMatches(t *testing.T) {
 				"for runner player did not match",
 			)
 		}
-		invalidp := m.GetPrestige(&Player{})
-		invalidop := m.GetOpponent(&Player{})
+		invalidp := m.GetPrestige(PlayerID(3), defaultScoring)
+		invalidop := m.GetOpponent(PlayerID(3))
 		if invalidp != 0 {
 			t.Error("For", data.desc,
 				"got prestige", invalidp,
 				"and opponent", invalidop,
-				"for non-participating player; expected 0 and nil",
+				"for non-participating player; expected 0 and NoPlayer",
 			)
 		}
 		winner := m.GetWinner()
@@ -89,24 +95,72 @@ func TestMatches(t *testing.T) {
 }
 
 func TestUnfinishedGame(t *testing.T) {
-	g := &Game{Pairing: Pairing{Corp: &c, Runner: &r}}
-	cp := g.CorpPrestige()
-	rp := g.RunnerPrestige()
+	g := &Game{Pairing: Pairing{Corp: c.PlayerID, Runner: r.PlayerID}}
+	cp := g.CorpPrestige(defaultScoring)
+	rp := g.RunnerPrestige(defaultScoring)
 	if cp != 0 || rp != 0 {
 		t.Error("For unfinished game, expected corp prestige 0 and runner prestige 0, got corp prestige", cp, "and runner prestige", rp)
 	}
 }
 
+var twoGameTests = []struct {
+	winner1  PlayerID
+	winner2  PlayerID
+	prestige [2]int
+	winner   PlayerID
+	desc     string
+}{
+	{c.PlayerID, r.PlayerID, [2]int{3, 3}, NoPlayer, "Split"},
+	{c.PlayerID, c.PlayerID, [2]int{6, 0}, c.PlayerID, "Alice wins both"},
+	{r.PlayerID, NoPlayer, [2]int{1, 4}, r.PlayerID, "Bob wins one and ties one"},
+	{NoPlayer, NoPlayer, [2]int{2, 2}, NoPlayer, "Two ties"},
+}
+
+func TestTwoGameMatches(t *testing.T) {
+	for _, data := range twoGameTests {
+		m := Match{Game: Game{Pairing: Pairing{Corp: c.PlayerID, Runner: r.PlayerID}}}
+		m.SecondGame = &Game{Pairing: Pairing{Corp: r.PlayerID, Runner: c.PlayerID}}
+		m.Game.RecordResult(data.winner1, false)
+		if m.IsDone() {
+			t.Error("For", data.desc, "match with one game recorded returned true for IsDone()")
+		}
+		m.SecondGame.RecordResult(data.winner2, false)
+		if !m.IsDone() {
+			t.Error("For", data.desc, "match with both games recorded returned false for IsDone()")
+		}
+		cp := m.GetPrestige(c.PlayerID, defaultScoring)
+		rp := m.GetPrestige(r.PlayerID, defaultScoring)
+		if cp != data.prestige[0] || rp != data.prestige[1] {
+			t.Error("For", data.desc,
+				"expected prestige", data.prestige,
+				"got", cp, "and", rp,
+			)
+		}
+		if m.GetWinner() != data.winner {
+			t.Error("For", data.desc,
+				"got winner", m.GetWinner(),
+				"expected", data.winner,
+			)
+		}
+	}
+
+	bye := Match{Game: Game{Pairing: Pairing{Corp: c.PlayerID, Runner: NoPlayer}}}
+	bye.SecondGame = &Game{Pairing: Pairing{Corp: NoPlayer, Runner: c.PlayerID}}
+	if bye.GetPrestige(c.PlayerID, defaultScoring) != 6 {
+		t.Error("Expected prestige 6 for a bye in a two-game round, got", bye.GetPrestige(c.PlayerID, defaultScoring))
+	}
+}
+
 // Round goodness tests
 
 var emptyGoodness = roundGoodness{}
 
 var idealPairing = pairingDetails{rematch: 0, groupDiff: 0, sideDiffs: [2]int{0, 0}, streaks: [2]int{1, 1}}
-var groupCrossingPairing = pairingDetails{0, 1, [2]int{0, 0}, [2]int{1, 1}}
-var rematchPairing = pairingDetails{1, 0, [2]int{0, 0}, [2]int{1, 1}}
-var sideDiffsPairing = pairingDetails{0, 0, [2]int{1, 2}, [2]int{1, 1}}
-var streaksPairing = pairingDetails{0, 0, [2]int{0, 0}, [2]int{1, 2}}
-var messyPairing = pairingDetails{0, 2, [2]int{2, 1}, [2]int{1, 2}}
+var groupCrossingPairing = pairingDetails{0, false, 1, [2]int{0, 0}, [2]int{1, 1}, false, 0}
+var rematchPairing = pairingDetails{1, false, 0, [2]int{0, 0}, [2]int{1, 1}, false, 0}
+var sideDiffsPairing = pairingDetails{0, false, 0, [2]int{1, 2}, [2]int{1, 1}, false, 0}
+var streaksPairing = pairingDetails{0, false, 0, [2]int{0, 0}, [2]int{1, 2}, false, 0}
+var messyPairing = pairingDetails{0, false, 2, [2]int{2, 1}, [2]int{1, 2}, false, 0}
 
 var addPairingTests = []struct {
 	in  roundGoodness
@@ -116,41 +170,44 @@ var addPairingTests = []struct {
 	{
 		emptyGoodness,
 		idealPairing,
-		roundGoodness{[]int{1}, []int{1}, []int{2}, []int{0, 2}},
+		roundGoodness{[]int{1}, 0, []int{1}, []int{2}, []int{0, 2}, false, 0, nil},
 	},
 	{
 		emptyGoodness,
 		groupCrossingPairing,
-		roundGoodness{[]int{1}, []int{0, 1}, []int{2}, []int{0, 2}},
+		roundGoodness{[]int{1}, 0, []int{0, 1}, []int{2}, []int{0, 2}, false, 0, nil},
 	},
 	{
 		emptyGoodness,
 		rematchPairing,
-		roundGoodness{[]int{0, 1}, []int{1}, []int{2}, []int{0, 2}},
+		roundGoodness{[]int{0, 1}, 0, []int{1}, []int{2}, []int{0, 2}, false, 0, nil},
 	},
 	{
 		emptyGoodness,
 		sideDiffsPairing,
-		roundGoodness{[]int{1}, []int{1}, []int{0, 1, 1}, []int{0, 2}},
+		roundGoodness{[]int{1}, 0, []int{1}, []int{0, 1, 1}, []int{0, 2}, false, 0, nil},
 	},
 	{
 		emptyGoodness,
 		streaksPairing,
-		roundGoodness{[]int{1}, []int{1}, []int{2}, []int{0, 1, 1}},
+		roundGoodness{[]int{1}, 0, []int{1}, []int{2}, []int{0, 1, 1}, false, 0, nil},
 	},
 	{
 		emptyGoodness,
 		messyPairing,
-		roundGoodness{[]int{1}, []int{0, 0, 1}, []int{0, 1, 1}, []int{0, 1, 1}},
+		roundGoodness{[]int{1}, 0, []int{0, 0, 1}, []int{0, 1, 1}, []int{0, 1, 1}, false, 0, nil},
 	},
 }
 
 func copyGoodness(g *roundGoodness) roundGoodness {
 	var c roundGoodness
 	c.rematches = append([]int(nil), g.rematches...)
+	c.restricted = g.restricted
 	c.sideDiffs = append([]int(nil), g.sideDiffs...)
 	c.streaks = append([]int(nil), g.streaks...)
 	c.groupDiffs = append([]int(nil), g.groupDiffs...)
+	c.hasBye = g.hasBye
+	c.byePrestige = g.byePrestige
 	return c
 }
 
@@ -186,6 +243,10 @@ func (g1 *roundGoodness) equals(g2 *roundGoodness) bool {
 		}
 	}
 
+	if g1.restricted != g2.restricted {
+		return false
+	}
+
 	return true
 }
 
@@ -204,24 +265,24 @@ func TestAddPairing(t *testing.T) {
 }
 
 // goodness for ideal round with 6 players paired
-var idealRoundGoodness = roundGoodness{[]int{3}, []int{3}, []int{6}, []int{0, 6}}
+var idealRoundGoodness = roundGoodness{[]int{3}, 0, []int{3}, []int{6}, []int{0, 6}, false, 0, nil}
 
 // these are ideal except in one aspect, still with 6 players paired
-var rematchGoodness = roundGoodness{[]int{2, 1}, []int{3}, []int{6}, []int{0, 6}}
-var multiRematchGoodness = roundGoodness{[]int{1, 2}, []int{3}, []int{6}, []int{0, 6}}
-var groupDiffGoodness = roundGoodness{[]int{3}, []int{2, 1}, []int{6}, []int{0, 6}}
-var worseGroupDiffGoodness = roundGoodness{[]int{3}, []int{1, 2}, []int{6}, []int{0, 6}}
-var mildSideDiffsGoodness = roundGoodness{[]int{3}, []int{3}, []int{0, 2, 4}, []int{0, 6}}
-var milderSideDiffsGoodness = roundGoodness{[]int{3}, []int{3}, []int{0, 4, 2}, []int{0, 6}}
-var mildStreaksGoodness = roundGoodness{[]int{3}, []int{3}, []int{6}, []int{0, 2, 4}}
-var milderStreaksGoodness = roundGoodness{[]int{3}, []int{3}, []int{6}, []int{0, 4, 2}}
-var badSideDiffsGoodness = roundGoodness{[]int{3}, []int{3}, []int{4, 0, 0, 2}, []int{0, 6}}
-var awfulSideDiffsGoodness = roundGoodness{[]int{3}, []int{3}, []int{2, 0, 0, 4}, []int{0, 6}}
-var badStreaksGoodness = roundGoodness{[]int{3}, []int{3}, []int{6}, []int{0, 4, 0, 2}}
-var awfulStreaksGoodness = roundGoodness{[]int{3}, []int{3}, []int{6}, []int{0, 2, 0, 4}}
+var rematchGoodness = roundGoodness{[]int{2, 1}, 0, []int{3}, []int{6}, []int{0, 6}, false, 0, nil}
+var multiRematchGoodness = roundGoodness{[]int{1, 2}, 0, []int{3}, []int{6}, []int{0, 6}, false, 0, nil}
+var groupDiffGoodness = roundGoodness{[]int{3}, 0, []int{2, 1}, []int{6}, []int{0, 6}, false, 0, nil}
+var worseGroupDiffGoodness = roundGoodness{[]int{3}, 0, []int{1, 2}, []int{6}, []int{0, 6}, false, 0, nil}
+var mildSideDiffsGoodness = roundGoodness{[]int{3}, 0, []int{3}, []int{0, 2, 4}, []int{0, 6}, false, 0, nil}
+var milderSideDiffsGoodness = roundGoodness{[]int{3}, 0, []int{3}, []int{0, 4, 2}, []int{0, 6}, false, 0, nil}
+var mildStreaksGoodness = roundGoodness{[]int{3}, 0, []int{3}, []int{6}, []int{0, 2, 4}, false, 0, nil}
+var milderStreaksGoodness = roundGoodness{[]int{3}, 0, []int{3}, []int{6}, []int{0, 4, 2}, false, 0, nil}
+var badSideDiffsGoodness = roundGoodness{[]int{3}, 0, []int{3}, []int{4, 0, 0, 2}, []int{0, 6}, false, 0, nil}
+var awfulSideDiffsGoodness = roundGoodness{[]int{3}, 0, []int{3}, []int{2, 0, 0, 4}, []int{0, 6}, false, 0, nil}
+var badStreaksGoodness = roundGoodness{[]int{3}, 0, []int{3}, []int{6}, []int{0, 4, 0, 2}, false, 0, nil}
+var awfulStreaksGoodness = roundGoodness{[]int{3}, 0, []int{3}, []int{6}, []int{0, 2, 0, 4}, false, 0, nil}
 
 // side diffs of one cannot and should not be avoided, so this is just as good as the ideal round
-var nearlyIdealRoundGoodness = roundGoodness{[]int{3}, []int{3}, []int{0, 6}, []int{0, 6}}
+var nearlyIdealRoundGoodness = roundGoodness{[]int{3}, 0, []int{3}, []int{0, 6}, []int{0, 6}, false, 0, nil}
 
 var goodnessesInOrder = []*roundGoodness{
 	&idealRoundGoodness,
@@ -261,3 +322,929 @@ func TestGoodnessComparison(t *testing.T) {
 		t.Error("Nearly ideal round compared better than ideal round; expected side diffs of one to be ignored")
 	}
 }
+
+func TestLastRoundGoodnessComparison(t *testing.T) {
+	for _, g := range []*roundGoodness{&badSideDiffsGoodness, &awfulSideDiffsGoodness, &badStreaksGoodness, &awfulStreaksGoodness} {
+		lastRound := *g
+		lastRound.tiers = lastRoundTiers
+		if !lastRound.BetterThan(&worseGroupDiffGoodness) {
+			t.Error("In last round, expected", g, "to be better than", worseGroupDiffGoodness)
+		}
+		ideal := idealRoundGoodness
+		ideal.tiers = lastRoundTiers
+		if !ideal.BetterThan(g) {
+			t.Error("In last round, expected", ideal, "to be better than", g)
+		}
+	}
+}
+
+// Pairing engine tests
+
+// playRandomRounds makes a tournament and plays the given number of rounds
+// with random results.
+func playRandomRounds(players, rounds int) *Tournament {
+	tn := &Tournament{}
+	for i := 0; i < players; i++ {
+		tn.AddPlayer(fmt.Sprintf("Player %d", i+1), "", "")
+	}
+	for i := 0; i < rounds; i++ {
+		playRandomRound(tn)
+	}
+	tn.Rounds[len(tn.Rounds)-1].Finish()
+	return tn
+}
+
+// playRandomRound pairs and starts the next round, and records random
+// results for it without finishing it.
+func playRandomRound(tn *Tournament) {
+	tn.NextRound(false)
+	r := &(tn.Rounds[len(tn.Rounds)-1])
+	r.Start()
+	for j := range r.Matches {
+		g := &(r.Matches[j].Game)
+		if g.Concluded {
+			continue
+		}
+		switch rand.Intn(3) {
+		case 0:
+			g.RecordResult(g.Corp, rand.Intn(2) == 0)
+		case 1:
+			g.RecordResult(g.Runner, rand.Intn(2) == 0)
+		default:
+			g.RecordResult(NoPlayer, false)
+		}
+	}
+}
+
+func pairingsGoodness(tn *Tournament, pairings []Pairing) roundGoodness {
+	var g roundGoodness
+	for _, p := range pairings {
+		g.addPairing(tn.pairingEffects(p.Corp, p.Runner))
+	}
+	return g
+}
+
+// allGoodnesses finds the goodness of every possible way to pair the players,
+// the slow way.
+func allGoodnesses(tn *Tournament, players []PlayerID, g roundGoodness, out *[]roundGoodness) {
+	if len(players) == 0 {
+		*out = append(*out, g)
+		return
+	}
+	for i, b := range players[1:] {
+		rest := append(append([]PlayerID(nil), players[1:i+1]...), players[i+2:]...)
+		sides := []Pairing{{Corp: players[0], Runner: b}}
+		if b != NoPlayer {
+			sides = append(sides, Pairing{Corp: b, Runner: players[0]})
+		}
+		for _, p := range sides {
+			next := copyGoodness(&g)
+			next.addPairing(tn.pairingEffects(p.Corp, p.Runner))
+			allGoodnesses(tn, rest, next, out)
+		}
+	}
+}
+
+func TestBestPairings(t *testing.T) {
+	for i := 0; i < 30; i++ {
+		tn := playRandomRounds(5+rand.Intn(4), 1+rand.Intn(4))
+		players := tn.activePlayers()
+		shuffleGroups(tn, tn.random(), players)
+		if i%3 == 0 {
+			tn.Player(players[0]).Team = "Store"
+			tn.Player(players[1]).Team = "store"
+			tn.AvoidPairing(players[2], players[3])
+		}
+		tiers := normalTiers
+		if i%2 == 1 {
+			tiers = lastRoundTiers
+		}
+		pairings, _ := tn.bestPairings(context.Background(), tn.random(), players, tiers, nil)
+
+		paired := make(map[PlayerID]bool)
+		for _, p := range pairings {
+			if paired[p.Corp] || paired[p.Runner] {
+				t.Fatal("Player paired twice in", pairings)
+			}
+			paired[p.Corp] = true
+			if p.Runner != NoPlayer {
+				paired[p.Runner] = true
+			}
+		}
+		if len(paired) != len(players) {
+			t.Fatal("Expected", len(players), "players paired, got", len(paired))
+		}
+
+		g := pairingsGoodness(tn, pairings)
+		g.tiers = tiers
+		if len(players)%2 == 1 {
+			players = append(players, NoPlayer)
+		}
+		var all []roundGoodness
+		allGoodnesses(tn, players, roundGoodness{}, &all)
+		for j := range all {
+			all[j].tiers = tiers
+			if all[j].BetterThan(&g) {
+				t.Error("For round", len(tn.Rounds)+1,
+					"got pairings", pairings,
+					"with goodness", g,
+					"but", all[j], "is better",
+				)
+				break
+			}
+		}
+	}
+}
+
+func TestRepeatByeIsRematch(t *testing.T) {
+	tn := playRandomRounds(3, 1)
+	for _, m := range tn.Rounds[0].Matches {
+		if !m.IsBye() {
+			continue
+		}
+		d := tn.pairingEffects(m.Corp, NoPlayer)
+		if d.rematch != 1 {
+			t.Error("For second bye, expected rematch 1, got", d.rematch)
+		}
+		if len(tn.Player(m.Corp).Byes) != 1 {
+			t.Error("Expected one bye recorded for player, got", tn.Player(m.Corp).Byes)
+		}
+		return
+	}
+	t.Error("No bye in round one with three players")
+}
+
+func TestNextRoundStopsAfterSwiss(t *testing.T) {
+	tn := playRandomRounds(4, 1)
+	tn.SwissRounds = 1
+	if e := tn.NextRound(false); e != errSwissFinished {
+		t.Error("Expected", errSwissFinished, "pairing past last swiss round, got", e)
+	}
+	if len(tn.Rounds) != 1 {
+		t.Error("Expected 1 round, got", len(tn.Rounds))
+	}
+	if e := tn.NextRound(true); e != nil {
+		t.Error("Expected extra round to be paired, got", e)
+	}
+	if e := tn.NextRound(true); e == nil {
+		t.Error("Paired another round while the last one hadn't started")
+	}
+	if len(tn.Rounds) != 2 {
+		t.Error("Expected 2 rounds, got", len(tn.Rounds))
+	}
+}
+
+func TestDraftRound(t *testing.T) {
+	tn := playRandomRounds(6, 2)
+	if e := tn.NextRound(false); e != nil {
+		t.Fatal("Couldn't pair round 3:", e)
+	}
+	d := tn.Draft()
+	if d == nil {
+		t.Fatal("New round isn't a draft")
+	}
+
+	m := d.Matches[0]
+	if e := d.FlipSides(1); e != nil {
+		t.Error("Couldn't swap sides:", e)
+	}
+	if d.Matches[0].Corp != m.Runner || d.Matches[0].Runner != m.Corp {
+		t.Error("Sides weren't swapped")
+	}
+
+	a, b := d.Matches[0].Corp, d.Matches[1].Runner
+	if e := d.SwapPlayers(a, b); e != nil {
+		t.Error("Couldn't swap players:", e)
+	}
+	if d.Matches[0].Corp != b || d.Matches[1].Runner != a {
+		t.Error("Players weren't swapped")
+	}
+	if len(d.Report.Matches) != len(d.Matches) {
+		t.Error("Report wasn't updated")
+	}
+	if e := d.SwapPlayers(a, a); e == nil {
+		t.Error("Swapped a player with themselves")
+	}
+
+	d.Start()
+	if tn.Draft() != nil {
+		t.Error("Started round is still a draft")
+	}
+	if tn.Player(b).CurrentMatch != (MatchID{3, 1}) {
+		t.Error("Swapped player has wrong current match", tn.Player(b).CurrentMatch)
+	}
+	if e := d.FlipSides(1); e != errRoundStarted {
+		t.Error("Expected", errRoundStarted, "changing started round, got", e)
+	}
+	if e := tn.DiscardDraft(); e == nil {
+		t.Error("Discarded a started round")
+	}
+}
+
+func TestPairingRestrictions(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		tn := &Tournament{}
+		for j := 0; j < 4; j++ {
+			tn.AddPlayer(fmt.Sprintf("Player %d", j+1), "", "")
+		}
+		tn.Player(1).Team = "Family"
+		tn.Player(2).Team = "family"
+		tn.AvoidPairing(3, 4)
+		tn.NextRound(false)
+		for _, m := range tn.Rounds[0].Matches {
+			if tn.restricted(m.Corp, m.Runner) {
+				t.Fatal("Restricted players paired in round 1:", tn.Rounds[0].Matches)
+			}
+		}
+	}
+
+	tn := &Tournament{RestrictionRounds: 2}
+	if !hasTier(tn.pairingTiers(2), restrictionTier) {
+		t.Error("Restrictions lifted too early")
+	}
+	if hasTier(tn.pairingTiers(3), restrictionTier) {
+		t.Error("Restrictions still apply after being lifted")
+	}
+
+	tn.Players = []Player{{PlayerID: 1}, {PlayerID: 2}}
+	tn.AvoidPairing(1, 2)
+	tn.AllowPairing(2, 1)
+	if tn.restricted(1, 2) || len(tn.Player(1).Avoid) != 0 {
+		t.Error("Restriction wasn't removed")
+	}
+	if tn.AvoidPairing(0, 1) == nil || tn.Player(0) != nil {
+		t.Error("Expected player 0 not to exist")
+	}
+}
+
+func TestEarnedByes(t *testing.T) {
+	tn := &Tournament{}
+	for i := 0; i < 6; i++ {
+		tn.AddPlayer(fmt.Sprintf("Player %d", i+1), "", "")
+	}
+	tn.Player(1).EarnedByes = 2
+	tn.Player(2).EarnedByes = 1
+
+	for round := 1; round <= 2; round++ {
+		tn.NextRound(false)
+		r := &(tn.Rounds[round-1])
+		r.Start()
+		byes := make(map[PlayerID]bool)
+		for j := range r.Matches {
+			m := &(r.Matches[j])
+			if m.IsBye() {
+				byes[m.Corp] = true
+				if m.EarnedBye != (m.Corp == 1 || (m.Corp == 2 && round == 1)) {
+					t.Error("In round", round, "player", m.Corp, "got a bye with EarnedBye", m.EarnedBye)
+				}
+			} else {
+				m.Game.RecordResult(m.Corp, false)
+			}
+		}
+		if !byes[1] || byes[2] != (round == 1) {
+			t.Error("Wrong players got byes in round", round, byes)
+		}
+		if r.Report.Bye != nil && r.Report.Bye.Player == 1 {
+			t.Error("Earned bye reported as chosen by the pairing engine")
+		}
+	}
+	tn.Rounds[1].Finish()
+
+	if p := tn.Player(1); p.Prestige != 6 || len(p.Byes) != 2 {
+		t.Error("Expected 6 prestige and 2 byes for player with two earned byes, got", p.Prestige, "and", len(p.Byes))
+	}
+}
+
+func TestReplayPairings(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		tn := playRandomRounds(5+rand.Intn(6), 1+rand.Intn(3))
+		tn.NextRound(false)
+		r := tn.Draft()
+		recorded := r.Pairings()
+		r.pair(context.Background(), r.Seed, nil)
+		replayed := r.Pairings()
+		for j := range recorded {
+			if recorded[j] != replayed[j] {
+				t.Fatal("Pairing again with seed", r.Seed, "got", replayed, "instead of", recorded)
+			}
+		}
+	}
+}
+
+func TestPairingTimeout(t *testing.T) {
+	tn := playRandomRounds(21, 3)
+	players := tn.activePlayers()
+	shuffleGroups(tn, tn.random(), players)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pairings, complete := tn.bestPairings(ctx, tn.random(), players, normalTiers, nil)
+	if complete {
+		t.Error("Pairing with a cancelled context claimed to be complete")
+	}
+	paired := make(map[PlayerID]bool)
+	for _, p := range pairings {
+		if paired[p.Corp] || paired[p.Runner] {
+			t.Fatal("Player paired twice in", pairings)
+		}
+		paired[p.Corp] = true
+		if p.Runner != NoPlayer {
+			paired[p.Runner] = true
+		}
+	}
+	if len(paired) != len(players) {
+		t.Error("Expected", len(players), "players paired after running out of time, got", len(paired))
+	}
+
+	var calls int
+	_, complete = tn.bestPairings(context.Background(), tn.random(), players, normalTiers, func(done, total int) {
+		calls += 1
+		if done > total {
+			t.Error("Progress", done, "of", total)
+		}
+	})
+	if !complete || calls == 0 {
+		t.Error("Expected complete pairing with progress reports, got complete", complete, "and", calls, "reports")
+	}
+}
+
+func TestMatchingTimeout(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var edges []weightedEdge
+	for i := 0; i < 20; i++ {
+		for j := i + 1; j < 20; j++ {
+			edges = append(edges, weightedEdge{i, j, big.NewInt(rng.Int63n(1000))})
+		}
+	}
+	greedy := make([]int, 20)
+	for v := range greedy {
+		greedy[v] = -1
+	}
+	completeMatching(edges, greedy)
+
+	// run out of time a few stages in
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mates, complete := maxWeightMatching(ctx, edges, func(done, total int) {
+		if done == 3 {
+			cancel()
+		}
+	})
+	if complete {
+		t.Error("Matching that ran out of time claimed to be complete")
+	}
+	for v, w := range mates {
+		if w == -1 || mates[w] != v {
+			t.Fatal("Expected a complete matching after running out of time, got", mates)
+		}
+	}
+	if betterMatching(edges, greedy, mates) {
+		t.Error("Matching after running out of time is worse than a greedy one")
+	}
+
+	best, complete := maxWeightMatching(context.Background(), edges, nil)
+	if !complete || betterMatching(edges, mates, best) {
+		t.Error("Matching that ran out of time is better than the best one")
+	}
+
+	// a round paired without time is still complete, and says so
+	tn := playRandomRounds(21, 3)
+	rd := Round{Tournament: tn, Number: 4}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	rd.MakeMatchesContext(cancelled, nil)
+	if !rd.TimedOut {
+		t.Error("Round paired without time wasn't marked as timed out")
+	}
+	paired := make(map[PlayerID]bool)
+	for _, m := range rd.Matches {
+		paired[m.Corp] = true
+		paired[m.Runner] = true
+	}
+	for _, p := range tn.activePlayers() {
+		if !paired[p] {
+			t.Error("Player", p, "not paired after running out of time")
+		}
+	}
+}
+
+func TestPairingProfiles(t *testing.T) {
+	tn := &Tournament{SwissRounds: 4}
+	if !hasTier(tn.pairingTiers(2), sideDiffTier) || tn.pairingTiers(2)[3] != sideDiffTier {
+		t.Error("Default profile should consider side diffs before score groups, got", tn.pairingTiers(2))
+	}
+	if tn.pairingTiers(4)[3] != groupDiffTier {
+		t.Error("Default profile should consider score groups first in the last round, got", tn.pairingTiers(4))
+	}
+
+	tn.PairingProfile = "nrtm"
+	if tn.pairingTiers(2)[3] != groupDiffTier {
+		t.Error("NRTM profile should consider score groups before side diffs, got", tn.pairingTiers(2))
+	}
+
+	tn.PairingProfile = "casual"
+	for _, tier := range sideTiers {
+		if hasTier(tn.pairingTiers(2), tier) {
+			t.Error("Casual profile considers sides:", tn.pairingTiers(2))
+		}
+	}
+
+	tn.PairingProfile = "no such profile"
+	if tn.Profile().Key != "fide" {
+		t.Error("Unknown profile should fall back to the default, got", tn.Profile().Key)
+	}
+
+	tn.TwoGameRounds = true
+	for _, tier := range sideTiers {
+		if hasTier(tn.pairingTiers(2), tier) {
+			t.Error("Two-game rounds consider sides:", tn.pairingTiers(2))
+		}
+	}
+}
+
+func TestIntentionalDraw(t *testing.T) {
+	g := Game{Pairing: Pairing{Corp: c.PlayerID, Runner: r.PlayerID}}
+	g.RecordIntentionalDraw(2)
+	if g.CorpPrestige(defaultScoring) != 2 || g.RunnerPrestige(defaultScoring) != 2 {
+		t.Error("Expected 2 prestige each for intentional draw, got", g.CorpPrestige(defaultScoring), "and", g.RunnerPrestige(defaultScoring))
+	}
+	g.RecordResult(NoPlayer, false)
+	if g.IntentionalDraw || g.CorpPrestige(defaultScoring) != 1 {
+		t.Error("Recording a tie over an intentional draw didn't clear it")
+	}
+
+	tn := playRandomRounds(4, 1)
+	before, _ := tn.playerCorpEffects(1)
+	tn.NextRound(false)
+	rd := &(tn.Rounds[1])
+	rd.Start()
+	for i := range rd.Matches {
+		rd.Matches[i].Game.RecordIntentionalDraw(tn.IntentionalDrawPrestige())
+	}
+	rd.Finish()
+	if after, _ := tn.playerCorpEffects(1); after != before {
+		t.Error("Intentional draw counted towards side diff: was", before, "now", after)
+	}
+}
+
+func TestPenaltyResults(t *testing.T) {
+	m := Match{Game: Game{Pairing: Pairing{Corp: c.PlayerID, Runner: r.PlayerID}}}
+	m.Game.RecordDoubleLoss("Slow play")
+	if m.GetPrestige(c.PlayerID, defaultScoring) != 0 || m.GetPrestige(r.PlayerID, defaultScoring) != 0 || m.GetWinner() != NoPlayer {
+		t.Error("Expected no prestige and no winner for a double loss, got", m.GetPrestige(c.PlayerID, defaultScoring), m.GetPrestige(r.PlayerID, defaultScoring), m.GetWinner())
+	}
+	if m.Game.Ruling != "Slow play" {
+		t.Error("Ruling not recorded")
+	}
+
+	m.Game.RecordGameLoss(c.PlayerID, "Marked cards")
+	if m.GetPrestige(c.PlayerID, defaultScoring) != 0 || m.GetPrestige(r.PlayerID, defaultScoring) != 3 || m.GetWinner() != r.PlayerID {
+		t.Error("Expected a normal win for the opponent of a player with a game loss, got", m.GetPrestige(c.PlayerID, defaultScoring), m.GetPrestige(r.PlayerID, defaultScoring), m.GetWinner())
+	}
+
+	m.Game.RecordResult(c.PlayerID, false)
+	if m.Game.GameLoss || m.Game.Ruling != "" {
+		t.Error("Recording a normal result didn't clear the penalty")
+	}
+}
+
+func TestNoShows(t *testing.T) {
+	tn := &Tournament{NoShowDropLimit: 1}
+	for i := 0; i < 4; i++ {
+		tn.AddPlayer(fmt.Sprintf("Player %d", i+1), "", "")
+	}
+	tn.NextRound(false)
+	rd := &(tn.Rounds[0])
+	rd.Start()
+	absent := rd.Matches[0].Runner
+	winner := rd.Matches[0].Corp
+	rd.Matches[0].Game.RecordForfeit(absent)
+	rd.Matches[1].Game.RecordResult(rd.Matches[1].Corp, false)
+	dropped, _ := rd.Finish()
+	if len(dropped) != 1 || dropped[0] != absent {
+		t.Error("Expected Finish to return the dropped player, got", dropped)
+	}
+
+	if tn.Player(winner).Prestige != 3 || len(tn.Player(winner).Byes) != 1 {
+		t.Error("Expected a win counted as a bye for forfeit winner, got", tn.Player(winner).Prestige, "prestige and", len(tn.Player(winner).Byes), "byes")
+	}
+	if tn.NoShows(absent) != 1 || !tn.Player(absent).Dropped {
+		t.Error("Expected player with a no-show to be dropped")
+	}
+	if sideDiff, _ := tn.playerCorpEffects(winner); sideDiff != 1 {
+		t.Error("Forfeit counted towards side diff:", sideDiff)
+	}
+	if other := rd.Matches[1].Corp; tn.Player(other).Dropped {
+		t.Error("Player who showed up was dropped")
+	}
+
+	// the forfeit was entered by mistake
+	rd.Matches[0].Game.RecordResult(absent, false)
+	dropped, reAdded := tn.CorrectResults(1)
+	if len(dropped) != 0 || len(reAdded) != 1 || tn.Player(absent).Dropped {
+		t.Error("Expected the player to be re-added once the no-show was corrected, got", dropped, reAdded)
+	}
+	rd.Matches[0].Game.RecordForfeit(absent)
+	dropped, reAdded = tn.CorrectResults(1)
+	if len(dropped) != 1 || len(reAdded) != 0 || !tn.Player(absent).Dropped {
+		t.Error("Expected the player to be dropped again, got", dropped, reAdded)
+	}
+
+	// dropping or re-adding by hand is up to the TO
+	tn.ReAddPlayer(absent)
+	rd.Matches[0].Game.RecordResult(absent, false)
+	tn.CorrectResults(1)
+	rd.Matches[0].Game.RecordResult(winner, false)
+	if _, reAdded = tn.CorrectResults(1); len(reAdded) != 0 || tn.Player(absent).Dropped {
+		t.Error("Player re-added by hand was changed by a correction")
+	}
+}
+
+func TestAdjustments(t *testing.T) {
+	tn := &Tournament{}
+	for i := 0; i < 5; i++ {
+		tn.AddPlayer(fmt.Sprintf("Player %d", i+1), "", "")
+	}
+	tn.NextRound(false)
+	rd := &(tn.Rounds[0])
+	rd.Start()
+	for i := range rd.Matches {
+		if !rd.Matches[i].IsBye() {
+			rd.Matches[i].Game.RecordResult(rd.Matches[i].Corp, false)
+		}
+	}
+	rd.Finish()
+
+	leader := tn.Standings[0]
+	if len(tn.Player(leader).Byes) != 0 {
+		// a second bye would be a repeat
+		leader = tn.Standings[1]
+	}
+	if e := tn.AddAdjustment(Adjustment{Player: leader, Round: 1, Prestige: -5, Reason: "Marked cards"}); e != nil {
+		t.Fatal(e)
+	}
+	if tn.Player(leader).Prestige != -2 {
+		t.Error("Expected adjusted prestige -2, got", tn.Player(leader).Prestige)
+	}
+	if tn.Standings[len(tn.Standings)-1] != leader {
+		t.Error("Standings not re-sorted after adjustment")
+	}
+	if e := tn.AddAdjustment(Adjustment{Player: leader, Round: 1, Prestige: 1}); e == nil {
+		t.Error("Adjustment without a reason was accepted")
+	}
+	if e := tn.AddAdjustment(Adjustment{Player: leader, Round: 2, Prestige: 1, Reason: "Too soon"}); e == nil {
+		t.Error("Adjustment for a round that doesn't exist was accepted")
+	}
+
+	// the player with negative prestige should get the bye
+	if e := tn.NextRound(false); e != nil {
+		t.Fatal(e)
+	}
+	for _, m := range tn.Rounds[1].Matches {
+		if m.IsBye() && m.Corp != leader {
+			t.Error("Bye went to", tn.Player(m.Corp).Name, "rather than the player with negative prestige")
+		}
+	}
+
+	if e := tn.RemoveAdjustment(0); e != nil {
+		t.Fatal(e)
+	}
+	if tn.Player(leader).Prestige != 3 || len(tn.Player(leader).Adjustments()) != 0 {
+		t.Error("Adjustment not undone")
+	}
+}
+
+func TestCorrectResults(t *testing.T) {
+	tn := playRandomRounds(7, 3)
+	tn.AddAdjustment(Adjustment{Player: tn.Standings[0], Round: 3, Prestige: -1, Reason: "Slow play"})
+	before := make(map[PlayerID]Player)
+	for _, p := range tn.Players {
+		before[p.PlayerID] = p
+	}
+	tn.RecomputeStandings()
+	for _, p := range tn.Players {
+		b := before[p.PlayerID]
+		if p.Prestige != b.Prestige || len(p.FinishedMatches) != len(b.FinishedMatches) || len(p.Byes) != len(b.Byes) || p.SoS != b.SoS {
+			t.Error("Recomputed standings differ for", p.Name)
+		}
+	}
+
+	var m *Match
+	for i := range tn.Rounds[0].Matches {
+		if !tn.Rounds[0].Matches[i].IsBye() {
+			m = &(tn.Rounds[0].Matches[i])
+			break
+		}
+	}
+	corpBefore := tn.Player(m.Corp).Prestige - m.GetPrestige(m.Corp, defaultScoring)
+	runnerBefore := tn.Player(m.Runner).Prestige - m.GetPrestige(m.Runner, defaultScoring)
+	m.Game.RecordResult(m.Corp, false)
+	tn.CorrectResults(1)
+	if tn.Player(m.Corp).Prestige != corpBefore+3 || tn.Player(m.Runner).Prestige != runnerBefore {
+		t.Error("Correction not reflected in prestige")
+	}
+	if tn.Rounds[0].Outdated || !tn.Rounds[1].Outdated || !tn.Rounds[2].Outdated {
+		t.Error("Expected only later rounds to be outdated")
+	}
+}
+
+func TestStandingsAfter(t *testing.T) {
+	tn := playRandomRounds(9, 2)
+	after2 := make(map[PlayerID]Player)
+	for _, p := range tn.Players {
+		after2[p.PlayerID] = p
+	}
+	order := append([]PlayerID(nil), tn.Standings...)
+	playRandomRound(tn)
+	playRandomRound(tn)
+	tn.Rounds[3].Finish()
+
+	s := tn.StandingsAfter(2)
+	for i, stats := range s.Players {
+		p := after2[stats.Player]
+		if stats.Prestige != p.Prestige || stats.SoS != p.SoS || stats.XSoS != p.XSoS || len(stats.FinishedMatches) != 2 || len(stats.Byes) != len(p.Byes) {
+			t.Error("Standings after round 2 differ for", p.Name)
+		}
+		if o := after2[order[i]]; o.Prestige != p.Prestige || o.SoS != p.SoS || o.XSoS != p.XSoS {
+			t.Error("Standings after round 2 in the wrong order")
+		}
+		if stats.CorpGames+stats.RunnerGames+len(stats.Byes) != 2 {
+			t.Error("Expected 2 games or byes for", p.Name, "got", stats.CorpGames, stats.RunnerGames, len(stats.Byes))
+		}
+	}
+
+	// the latest standings are the players' current stats
+	s = tn.StandingsAfter(len(tn.Rounds))
+	for i, stats := range s.Players {
+		if stats.Player != tn.Standings[i] || stats.Prestige != tn.Player(stats.Player).Prestige {
+			t.Error("Latest standings don't match the current standings")
+		}
+	}
+}
+
+func TestScoringSchemes(t *testing.T) {
+	tn := &Tournament{ScoringScheme: "ffg"}
+	for i := 0; i < 3; i++ {
+		tn.AddPlayer(fmt.Sprintf("Player %d", i+1), "", "")
+	}
+	tn.NextRound(false)
+	rd := &(tn.Rounds[0])
+	rd.Start()
+	var winner, bye PlayerID
+	var played Match
+	for i := range rd.Matches {
+		m := &(rd.Matches[i])
+		if m.IsBye() {
+			bye = m.Corp
+		} else {
+			winner = m.Corp
+			m.Game.RecordResult(winner, true)
+			played = *m
+		}
+	}
+	rd.Finish()
+	if tn.Player(winner).Prestige != 1 || tn.Player(bye).Prestige != 2 {
+		t.Error("Expected 1 prestige for a timed win and 2 for a bye under legacy FFG scoring, got", tn.Player(winner).Prestige, "and", tn.Player(bye).Prestige)
+	}
+
+	nsg := Scoring{}
+	for _, s := range scoringSchemes {
+		if s.Key == "nsg" {
+			nsg = s
+		}
+	}
+	if played.GetPrestige(winner, nsg) != 3 {
+		t.Error("Expected a timed win to be a full win under NSG scoring, got", played.GetPrestige(winner, nsg))
+	}
+	if tn.IntentionalDrawPrestige() != 0 {
+		t.Error("Expected the scoring scheme's prestige for an intentional draw, got", tn.IntentionalDrawPrestige())
+	}
+}
+
+func TestTiebreakers(t *testing.T) {
+	tn := playRandomRounds(10, 3)
+	tn.Tiebreakers = []string{"h2h", "median", "buchholz", "side-sos", "byes", "coin"}
+	tn.RecomputeStandings()
+	order := append([]PlayerID(nil), tn.Standings...)
+
+	s := tn.currentStandings()
+	for i := 1; i < len(tn.Standings); i++ {
+		a, b := s.Player(tn.Standings[i-1]), s.Player(tn.Standings[i])
+		if s.compare(a, b) < 0 {
+			t.Error("Standings out of order at", i)
+		}
+		if s.compare(a, b) == 0 {
+			t.Error("Coin flip left a tie at", i)
+		}
+	}
+
+	// the coin flip is recorded, so the order doesn't change
+	tn.RecomputeStandings()
+	for i := range order {
+		if tn.Standings[i] != order[i] {
+			t.Error("Standings changed on recomputing")
+			break
+		}
+	}
+
+	m := tn.Rounds[0].Matches[0]
+	if !m.IsBye() && m.GetWinner() != NoPlayer {
+		winner, loser := s.Player(m.GetWinner()), s.Player(m.GetOpponent(m.GetWinner()))
+		if n, _ := s.headToHead(winner, loser); n < 1 {
+			t.Error("Expected head to head to favour the winner")
+		}
+		if n, _ := s.headToHead(loser, winner); n > -1 {
+			t.Error("Expected head to head to count against the loser")
+		}
+	}
+}
+
+func TestHeadToHeadCycle(t *testing.T) {
+	tn := &Tournament{Tiebreakers: []string{"h2h", "median", "buchholz", "coin"}}
+	for i := 0; i < 3; i++ {
+		tn.AddPlayer(fmt.Sprintf("Player %d", i+1), "", "")
+		tn.Players[i].Tiebreak = float64(i) / 10
+	}
+	// 1 beats 2, 2 beats 3 and 3 beats 1, and each has a bye
+	for i, winner := range []PlayerID{1, 2, 3} {
+		loser := winner%3 + 1
+		rd := Round{Tournament: tn, Number: i + 1}
+		rd.setPairings([]Pairing{{Corp: winner, Runner: loser}, {Corp: loser%3 + 1, Runner: NoPlayer}})
+		rd.Start()
+		rd.Matches[0].Game.RecordResult(winner, false)
+		tn.Rounds = append(tn.Rounds, rd)
+		tn.Rounds[i].Finish()
+	}
+
+	// head to head can't separate them, so the coin flip has to
+	for _, order := range [][]PlayerID{{1, 2, 3}, {3, 1, 2}, {2, 3, 1}} {
+		tn.Standings = order
+		tn.RecomputeStandings()
+		if tn.Standings[0] != 3 || tn.Standings[1] != 2 || tn.Standings[2] != 1 {
+			t.Error("Expected the coin flip to rank a head to head cycle, got", tn.Standings, "from", order)
+		}
+	}
+
+	// but it does separate two of them if only they're tied
+	tn.AddAdjustment(Adjustment{Player: 3, Round: 3, Prestige: 1, Reason: "Test"})
+	s := tn.currentStandings()
+	if tn.Standings[0] != 3 || tn.Standings[1] != 1 || s.compare(s.Player(1), s.Player(2)) <= 0 {
+		t.Error("Expected head to head to rank the two tied players, got", tn.Standings)
+	}
+}
+
+func TestPlayerAddedAfterStandings(t *testing.T) {
+	tn := playRandomRounds(6, 1)
+	tn.currentStandings()
+	tn.AddPlayer("Late", "", "")
+	if e := tn.NextRound(false); e != nil {
+		t.Fatal(e)
+	}
+	if tn.currentStandings().Player(PlayerID(len(tn.Players))) == nil {
+		t.Error("New player missing from the standings")
+	}
+}
+
+func TestRecordedTiebreaks(t *testing.T) {
+	tn := &Tournament{}
+	for i := 0; i < 8; i++ {
+		tn.AddPlayer(fmt.Sprintf("Player %d", i+1), "", "")
+	}
+	tn.RecomputeStandings()
+	order := append([]PlayerID(nil), tn.Standings...)
+	for i := 1; i < len(order); i++ {
+		if tn.Player(order[i-1]).Tiebreak < tn.Player(order[i]).Tiebreak {
+			t.Error("Tied players not ranked by their recorded tiebreaks")
+		}
+	}
+	for n := 0; n < 5; n++ {
+		tn.RecomputeStandings()
+		for i := range order {
+			if tn.Standings[i] != order[i] {
+				t.Fatal("Tied players changed places")
+			}
+		}
+	}
+}
+
+func TestLateRegistration(t *testing.T) {
+	tn := playRandomRounds(6, 2)
+	tn.AddPlayer("Late", "", "")
+	late := PlayerID(len(tn.Players))
+	if tn.Player(late).MissedRounds != 2 {
+		t.Fatal("Expected 2 missed rounds, got", tn.Player(late).MissedRounds)
+	}
+	if tn.Player(late).Prestige != 0 {
+		t.Error("Missed rounds counted for nothing gave prestige", tn.Player(late).Prestige)
+	}
+
+	tn.SetMissedRounds(late, MissedHalfBye)
+	stats := tn.StandingsAfter(2).Player(late)
+	if stats.Prestige != 2 || stats.PrestigeAvg != 1 || stats.SoS != 0 || len(stats.Byes) != 0 {
+		t.Error("Expected half-byes worth a tie each, not counted as byes or towards SoS, got", stats.Prestige, stats.PrestigeAvg, stats.SoS, len(stats.Byes))
+	}
+	if tn.Player(late).Prestige != 2 {
+		t.Error("Standings not updated for half-byes")
+	}
+	if tn.StandingsAfter(1).Player(late).Prestige != 1 {
+		t.Error("Expected one half-bye after round 1")
+	}
+
+	tn.SetMissedRounds(late, MissedLoss)
+	stats = tn.StandingsAfter(2).Player(late)
+	if stats.Prestige != 0 || stats.MissedRounds != 2 {
+		t.Error("Expected missed rounds to count as losses, got", stats.Prestige, stats.MissedRounds)
+	}
+
+	// the late player is paired from the next round
+	if e := tn.NextRound(false); e != nil {
+		t.Fatal(e)
+	}
+	found := false
+	for _, m := range tn.Rounds[2].Matches {
+		found = found || m.Corp == late || m.Runner == late
+	}
+	if !found {
+		t.Error("Late player wasn't paired")
+	}
+
+	// a player who registers after the round is paired misses it unless
+	// it's paired again
+	tn.AddPlayer("Later", "", "")
+	later := PlayerID(len(tn.Players))
+	tn.SetMissedRounds(later, MissedLoss)
+	if missing := tn.Rounds[2].MissingPlayers(); len(missing) != 1 || missing[0] != later {
+		t.Error("Expected the new player to be missing from the draft, got", missing)
+	}
+	tn.Rounds[2].Start()
+	if tn.Player(later).MissedRounds != 3 || tn.Player(late).MissedRounds != 2 {
+		t.Error("Expected only the player left out of round 3 to have missed it, got", tn.Player(later).MissedRounds, tn.Player(late).MissedRounds)
+	}
+	for i := range tn.Rounds[2].Matches {
+		m := &(tn.Rounds[2].Matches[i])
+		if !m.IsBye() {
+			m.Game.RecordResult(m.Corp, false)
+		}
+	}
+	tn.Rounds[2].Finish()
+	if stats := tn.currentStandings().Player(later); stats.MissedRounds != 3 {
+		t.Error("Expected the round the player was left out of to count as missed, got", stats.MissedRounds)
+	}
+}
+
+func TestRegistrationAndCheckIn(t *testing.T) {
+	tn := &Tournament{RequireCheckIn: true}
+	tn.AddPlayer("Typed in", "", "")
+	for _, name := range []string{"Alice", "Bob", "Carol", "Dan", "Typed in again"} {
+		if e := tn.Register(name, "Corp", "Runner"); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if tn.Register("alice", "", "") == nil {
+		t.Error("Duplicate registration accepted")
+	}
+	for i := 0; i < 4; i++ {
+		if e := tn.ApproveRegistration(0); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if e := tn.MergeRegistration(0, 1); e != nil {
+		t.Fatal(e)
+	}
+	if len(tn.Players) != 5 || len(tn.Registrations) != 0 || tn.Player(1).Name != "Typed in" || tn.Player(1).Corp != "Corp" {
+		t.Error("Expected 4 approved registrations and 1 merged into an existing player")
+	}
+
+	// registering as a player who's already entered can only be merged
+	if e := tn.Register("typed IN", "", ""); e != nil {
+		t.Fatal(e)
+	}
+	if tn.ApproveRegistration(0) == nil {
+		t.Error("Registration approved as a second entry for an existing player")
+	}
+	tn.RejectRegistration(0)
+
+	for p := PlayerID(1); p <= 4; p++ {
+		tn.CheckIn(p, true)
+	}
+	if e := tn.NextRound(false); e != nil {
+		t.Fatal(e)
+	}
+	if !tn.Player(5).Dropped {
+		t.Error("Player who didn't check in wasn't dropped")
+	}
+	for _, m := range tn.Rounds[0].Matches {
+		if m.IsBye() || m.Corp == 5 || m.Runner == 5 {
+			t.Error("Absent player or bye in round 1")
+		}
+	}
+	if tn.Register("Too late", "", "") == nil || tn.CheckIn(1, true) == nil {
+		t.Error("Registration and check-in still open after round 1 was paired")
+	}
+
+	// once the pairings are thrown away, the absent player can still check in
+	tn.DiscardDraft()
+	if e := tn.CheckIn(5, true); e != nil || tn.Player(5).Dropped {
+		t.Error("Player who checked in late is still dropped:", e)
+	}
+}